@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"ws_practice_1/internal/store"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type SetRolePayload struct {
+	Role string `json:"role" validate:"required,oneof=user moderator admin"`
+}
+
+type serverStatus struct {
+	ConnectedClients int `json:"connected_clients"`
+	QueuedPlayers    int `json:"queued_players"`
+	ActiveMatches    int `json:"active_matches"`
+	OpenRooms        int `json:"open_rooms"`
+	QueuedJudgeTasks int `json:"queued_judge_tasks"`
+}
+
+func (app *application) statusHandler(w http.ResponseWriter, r *http.Request) {
+	app.ws.mu.Lock()
+	status := serverStatus{
+		ConnectedClients: len(app.ws.clients),
+		ActiveMatches:    len(app.ws.matches),
+		OpenRooms:        len(app.ws.rooms),
+	}
+	app.ws.mu.Unlock()
+
+	status.QueuedPlayers = app.ws.queue.Len()
+	status.QueuedJudgeTasks = app.judgePool.Queued()
+
+	if err := app.jsonResponse(w, http.StatusOK, status); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// moderateDeleteUserHandler removes another user's account. Moderators may
+// only act on users ranked below them.
+func (app *application) moderateDeleteUserHandler(w http.ResponseWriter, r *http.Request) {
+	moderator := r.Context().Value(userCtx).(*store.User)
+
+	target, ok := app.userFromURL(w, r)
+	if !ok {
+		return
+	}
+
+	if !canModerate(moderator, target) {
+		app.forbiddenResponse(w, r, fmt.Errorf("user %d cannot moderate user %d", moderator.ID, target.ID))
+		return
+	}
+
+	if err := app.store.Users.Delete(r.Context(), target.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (app *application) setUserRoleHandler(w http.ResponseWriter, r *http.Request) {
+	admin := r.Context().Value(userCtx).(*store.User)
+
+	var payload SetRolePayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	target, ok := app.userFromURL(w, r)
+	if !ok {
+		return
+	}
+
+	// Admins can only change the roles of users below them, and only to a
+	// role below their own. That includes themselves, so there is always
+	// one left.
+	if !canModerate(admin, target) {
+		app.forbiddenResponse(w, r, fmt.Errorf("user %d cannot change the role of user %d", admin.ID, target.ID))
+		return
+	}
+	if !outranks(admin, &store.User{Role: payload.Role}) {
+		app.forbiddenResponse(w, r, fmt.Errorf("user %d cannot grant the %s role", admin.ID, payload.Role))
+		return
+	}
+
+	if err := app.store.Users.SetRole(r.Context(), target.ID, payload.Role); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	target.Role = payload.Role
+	if err := app.jsonResponse(w, http.StatusOK, target); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+func (app *application) userFromURL(w http.ResponseWriter, r *http.Request) (*store.User, bool) {
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return nil, false
+	}
+
+	user, err := app.store.Users.GetByID(r.Context(), userID)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return nil, false
+	}
+
+	return user, true
+}
+
+// canModerate reports whether moderator may act on target's account, which
+// needs a strictly higher role than theirs.
+func canModerate(moderator, target *store.User) bool {
+	return moderator.ID != target.ID && outranks(moderator, target)
+}
+
+// outranks reports whether a holds a strictly higher role than b.
+func outranks(a, b *store.User) bool {
+	return a.HasRole(b.Role) && !b.HasRole(a.Role)
+}
@@ -8,9 +8,13 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 	"ws_practice_1/internal/auth"
+	"ws_practice_1/internal/judge"
+	"ws_practice_1/internal/mailer"
+	"ws_practice_1/internal/oauth"
 	"ws_practice_1/internal/store"
 
 	"github.com/go-chi/chi/v5"
@@ -22,7 +26,13 @@ type application struct {
 	config        config
 	store         store.Storage
 	authenticator auth.Authenticator
-	ws            wsApp
+	ws            *wsApp
+	judge         judge.Judge
+	judgePool     *judge.Pool
+	wsTickets     *auth.Tickets
+	mailer        mailer.Mailer
+	oauth         map[string]*oauth.Provider
+	oauthFlows    *oauth.Flows
 }
 
 type config struct {
@@ -30,23 +40,93 @@ type config struct {
 	db     dbConfig
 	env    string
 	apiURL string
+	// appURL is where the frontend lives; email links and OAuth sign-ins
+	// lead back there.
+	appURL string
 	auth   authConfig
+	cors   corsConfig
+	mail   mailConfig
+	oauth  oauthConfig
+	judge  judgeConfig
+	queue  queueConfig
+	rooms  roomConfig
+	match  matchConfig
 }
 
 type authConfig struct {
-	basic basicConfig
-	token tokenConfig
+	token       tokenConfig
+	wsTicketTTL time.Duration
 }
 
-type basicConfig struct {
-	user string
-	pass string
+type corsConfig struct {
+	// allowedOrigins may contain patterns, see matchOrigin.
+	allowedOrigins []string
+}
+
+type mailConfig struct {
+	backend          string
+	from             string
+	fileDir          string
+	smtp             smtpConfig
+	verifyEmailTTL   time.Duration
+	resetPasswordTTL time.Duration
+}
+
+type smtpConfig struct {
+	host     string
+	port     int
+	username string
+	password string
+}
+
+type oauthConfig struct {
+	// callbackURL is the public URL of the OAuth routes. Each provider's
+	// redirect URL is callbackURL/{provider}/callback.
+	callbackURL string
+	flowTTL     time.Duration
+	// providers maps a provider name to its config. Providers without a
+	// client ID are left out.
+	providers map[string]oauth.Config
 }
 
 type tokenConfig struct {
-	secret string
-	exp    time.Duration
-	iss    string
+	secret     string
+	exp        time.Duration
+	refreshExp time.Duration
+	iss        string
+}
+
+type judgeConfig struct {
+	backend       judge.Config
+	memoryLimitKB int
+	pool          judgePoolConfig
+}
+
+type judgePoolConfig struct {
+	workers   int
+	queueSize int
+	perUser   int
+	timeout   time.Duration
+}
+
+type queueConfig struct {
+	initialWindow  int
+	windowGrowth   int
+	growthInterval time.Duration
+	maxWindow      int
+	tick           time.Duration
+}
+
+type roomConfig struct {
+	ttl time.Duration
+}
+
+type matchConfig struct {
+	duration             time.Duration
+	tick                 time.Duration
+	reconnectGrace       time.Duration
+	idleAfter            time.Duration
+	questionRatingWindow int
 }
 
 type dbConfig struct {
@@ -61,6 +141,38 @@ type dbConfig struct {
 	maxIdleTime  string
 }
 
+// originAllowed reports whether origin matches one of the configured
+// allowed origins. It gates both CORS and WebSocket upgrades.
+func (app *application) originAllowed(origin string) bool {
+	for _, pattern := range app.config.cors.allowedOrigins {
+		if matchOrigin(pattern, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchOrigin compares an origin with a pattern such as
+// https://myapp-*.vercel.app, where a single "*" stands for part of one
+// label of the host name, so preview deployments can be allowed without
+// listing each.
+func matchOrigin(pattern, origin string) bool {
+	pattern = strings.ToLower(pattern)
+	origin = strings.ToLower(origin)
+
+	prefix, suffix, wildcard := strings.Cut(pattern, "*")
+	if !wildcard {
+		return pattern == origin
+	}
+
+	if len(origin) < len(prefix)+len(suffix) || !strings.HasPrefix(origin, prefix) || !strings.HasSuffix(origin, suffix) {
+		return false
+	}
+
+	middle := origin[len(prefix) : len(origin)-len(suffix)]
+	return middle != "" && !strings.ContainsAny(middle, "/:@.")
+}
+
 func (app *application) mount() *chi.Mux {
 	r := chi.NewRouter()
 
@@ -69,7 +181,9 @@ func (app *application) mount() *chi.Mux {
 	r.Use(middleware.Logger)
 	r.Use(middleware.Recoverer)
 	r.Use(cors.Handler(cors.Options{
-		AllowedOrigins:   []string{"http://localhost:5173", "https://stupidcoder.vercel.app", "http://stupidcoder.vercel.app"},
+		AllowOriginFunc: func(r *http.Request, origin string) bool {
+			return app.originAllowed(origin)
+		},
 		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
 		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token", "X-Requested-With"},
 		ExposedHeaders:   []string{"Set-Cookie", "Content-Length", "Access-Control-Allow-Origin", "Access-Control-Allow-Credentials"},
@@ -94,19 +208,72 @@ func (app *application) mount() *chi.Mux {
 
 			r.Get("/stats", app.getUserStatsHandler)
 			r.Get("/totalMatchesPlayed", app.getTotalMatchesPlayedHandler)
+			r.Get("/ratingHistory", app.getRatingHistoryHandler)
 
 		})
 
+		r.Route("/rooms", func(r chi.Router) {
+			r.Use(app.AuthTokenMiddleware)
+			r.Post("/", app.createRoomHandler)
+			r.Get("/", app.listRoomsHandler)
+			r.Delete("/{code}", app.cancelRoomHandler)
+		})
+
+		r.Route("/admin", func(r chi.Router) {
+			r.Use(app.AuthTokenMiddleware)
+
+			r.With(app.RoleMiddleware(store.RoleAdmin)).Get("/status", app.statusHandler)
+
+			r.Route("/users/{userID}", func(r chi.Router) {
+				r.With(app.RoleMiddleware(store.RoleModerator)).Delete("/", app.moderateDeleteUserHandler)
+				r.With(app.RoleMiddleware(store.RoleAdmin)).Put("/role", app.setUserRoleHandler)
+			})
+
+			r.Route("/questions", func(r chi.Router) {
+				r.Use(app.RoleMiddleware(store.RoleAdmin))
+
+				r.Get("/", app.listQuestionsHandler)
+				r.Post("/", app.createQuestionHandler)
+
+				r.Route("/{questionID}", func(r chi.Router) {
+					r.Get("/", app.getQuestionHandler)
+					r.Put("/", app.updateQuestionHandler)
+					r.Delete("/", app.retireQuestionHandler)
+					r.Get("/testcases", app.listQuestionTestCasesHandler)
+					r.Put("/testcases", app.replaceQuestionTestCasesHandler)
+				})
+			})
+		})
+
+		r.Route("/submissions", func(r chi.Router) {
+			r.Use(app.AuthTokenMiddleware)
+			r.Get("/", app.listSubmissionsHandler)
+			r.Get("/{submissionID}", app.getSubmissionHandler)
+		})
+
 		r.Route("/ws", func(r chi.Router) {
-			r.With(app.ParamAuthTokenMiddleware).Get("/", app.wsHandler)
+			r.With(app.WSAuthMiddleware).Get("/", app.wsHandler)
+			r.With(app.AuthTokenMiddleware).Post("/ticket", app.createWSTicketHandler)
 		})
 
 		r.Route("/authentication", func(r chi.Router) {
 			r.Post("/create", app.registerUserHandler)
 			r.Post("/token", app.createTokenHandler)
+			r.Post("/refresh", app.refreshTokenHandler)
+			r.Post("/verify-email", app.verifyEmailHandler)
+			r.With(app.AuthTokenMiddleware).Post("/verify-email/resend", app.resendVerificationEmailHandler)
+			r.Post("/forgot-password", app.forgotPasswordHandler)
+			r.Post("/reset-password", app.resetPasswordHandler)
+
+			r.Route("/oauth/{provider}", func(r chi.Router) {
+				r.Get("/", app.oauthLoginHandler)
+				r.With(app.AuthTokenMiddleware).Get("/link", app.oauthLinkHandler)
+				r.Get("/callback", app.oauthCallbackHandler)
+			})
 			r.With(app.AuthTokenMiddleware).Get("/me", app.meHandler)
 			r.With(app.AuthTokenMiddleware).Get("/verify", app.verifyTokenHandler)
 			r.With(app.AuthTokenMiddleware).Post("/logout", app.logoutHandler)
+			r.With(app.AuthTokenMiddleware).Post("/logout-all", app.logoutAllHandler)
 		})
 	})
 
@@ -135,7 +302,22 @@ func (app *application) run(mux http.Handler) error {
 
 		fmt.Printf("Signal caught, %s", s.String())
 
-		shutdown <- srv.Shutdown(ctx)
+		err := srv.Shutdown(ctx)
+
+		// Submissions that were already accepted still get judged and
+		// saved, for as long as the shutdown deadline allows.
+		drained := make(chan struct{})
+		go func() {
+			app.judgePool.Close()
+			close(drained)
+		}()
+		select {
+		case <-drained:
+		case <-ctx.Done():
+			log.Println("Judge pool did not drain before the shutdown deadline")
+		}
+
+		shutdown <- err
 	}()
 
 	log.Printf("Server has started listening at port %s \n", srv.Addr)
@@ -0,0 +1,27 @@
+package main
+
+import "testing"
+
+func TestMatchOrigin(t *testing.T) {
+	tests := []struct {
+		pattern string
+		origin  string
+		want    bool
+	}{
+		{"https://app.example.com", "https://app.example.com", true},
+		{"https://app.example.com", "https://App.Example.com", true},
+		{"https://app.example.com", "https://other.example.com", false},
+		{"https://myapp-*.vercel.app", "https://myapp-git-main.vercel.app", true},
+		{"https://myapp-*.vercel.app", "https://myapp-.vercel.app", false},
+		{"https://myapp-*.vercel.app", "https://myapp-x.evil.vercel.app", false},
+		{"https://myapp-*.vercel.app", "https://myapp-x@evil.vercel.app", false},
+		{"https://myapp-*.vercel.app", "https://myapp-x:8080.vercel.app", false},
+		{"https://myapp-*.vercel.app", "http://myapp-x.vercel.app", false},
+	}
+
+	for _, tt := range tests {
+		if got := matchOrigin(tt.pattern, tt.origin); got != tt.want {
+			t.Errorf("matchOrigin(%q, %q) = %v, want %v", tt.pattern, tt.origin, got, tt.want)
+		}
+	}
+}
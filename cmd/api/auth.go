@@ -2,8 +2,10 @@ package main
 
 import (
 	"fmt"
+	"log"
 	"net/http"
 	"time"
+	"ws_practice_1/internal/auth"
 	"ws_practice_1/internal/store"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -55,6 +57,12 @@ func (app *application) registerUserHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	// The account works straight away; ranked play waits for the email
+	// to be verified.
+	if err := app.sendVerificationEmail(ctx, user); err != nil {
+		log.Printf("Error sending verification email to user %d: %v\n", user.ID, err)
+	}
+
 	if err := app.jsonResponse(w, http.StatusCreated, user); err != nil {
 		app.internalServerError(w, r, err)
 	}
@@ -93,53 +101,239 @@ func (app *application) createTokenHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	claims := jwt.MapClaims{
-		"sub": user.ID,
-		"exp": time.Now().Add(app.config.auth.token.exp).Unix(),
-		"iat": time.Now().Unix(),
-		"nbf": time.Now().Unix(),
-		"iss": app.config.auth.token.iss,
-		"aud": app.config.auth.token.iss,
+	tokens, err := app.startSession(w, r, user)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
 	}
 
-	token, err := app.authenticator.GenerateToken(claims)
+	if err := app.jsonResponse(w, http.StatusOK, tokens); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+type RefreshTokenPayload struct {
+	RefreshToken string `json:"refresh_token" validate:"required,max=100"`
+}
+
+// refreshTokenHandler trades a refresh token, from the cookie or the body,
+// for a new access token and a new refresh token. The old refresh token
+// stops working.
+func (app *application) refreshTokenHandler(w http.ResponseWriter, r *http.Request) {
+	var refreshToken string
+	if cookie, err := r.Cookie(refreshTokenCookie); err == nil {
+		refreshToken = cookie.Value
+	}
+
+	if refreshToken == "" {
+		var payload RefreshTokenPayload
+		if err := readJSON(w, r, &payload); err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+
+		if err := Validate.Struct(payload); err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+
+		refreshToken = payload.RefreshToken
+	}
+
+	newToken, newHash, err := auth.NewToken()
 	if err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
-	http.SetCookie(w, &http.Cookie{
-		Name:     "jwt",
-		Value:    token,
-		Path:     "/",
-		Domain:   "",
-		HttpOnly: true,
-		Secure:   true,
-		SameSite: http.SameSiteNoneMode,
-		Expires:  time.Now().Add(app.config.auth.token.exp),
-	})
 
-	w.Header().Set("Access-Control-Allow-Credentials", "true")
-	w.Header().Add("Access-Control-Expose-Headers", "Set-Cookie")
+	ctx := r.Context()
+	expiresAt := time.Now().Add(app.config.auth.token.refreshExp)
 
-	response := map[string]string{
-		"token": token,
+	session, err := app.store.Sessions.Rotate(ctx, auth.HashToken(refreshToken), newHash, expiresAt)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			clearAuthCookies(w)
+			app.unauthorizedErrorResponse(w, r, fmt.Errorf("refresh token is invalid or expired"))
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
 	}
-	if err := app.jsonResponse(w, http.StatusOK, response); err != nil {
+
+	user, err := app.store.Users.GetByID(ctx, session.UserID)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			clearAuthCookies(w)
+			app.unauthorizedErrorResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	tokens, err := app.issueTokens(w, user, session, newToken)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, tokens); err != nil {
 		app.internalServerError(w, r, err)
 	}
 }
 
+// logoutHandler revokes the current session, so its access and refresh
+// tokens stop working straight away.
 func (app *application) logoutHandler(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value(userCtx).(*store.User)
+	session := r.Context().Value(sessionCtx).(*store.Session)
+
+	if err := app.store.Sessions.Revoke(r.Context(), session.ID, user.ID); err != nil && err != store.ErrNotFound {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	clearAuthCookies(w)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// logoutAllHandler revokes every session of the user, signing out all of
+// their devices including this one.
+func (app *application) logoutAllHandler(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value(userCtx).(*store.User)
+
+	if _, err := app.store.Sessions.RevokeAllForUser(r.Context(), user.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	clearAuthCookies(w)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type wsTicketResponse struct {
+	Ticket    string    `json:"ticket"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// createWSTicketHandler issues a single-use ticket for opening the
+// WebSocket, passed as /ws?ticket=. It expires after a few seconds, so it
+// is harmless once it shows up in logs.
+func (app *application) createWSTicketHandler(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value(userCtx).(*store.User)
+	session := r.Context().Value(sessionCtx).(*store.Session)
+
+	id, ticket, err := app.wsTickets.Issue(user.ID, session.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	response := wsTicketResponse{Ticket: id, ExpiresAt: ticket.ExpiresAt}
+	if err := app.jsonResponse(w, http.StatusCreated, response); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+const (
+	accessTokenCookie  = "jwt"
+	refreshTokenCookie = "refresh_token"
+	// refreshCookiePath keeps the refresh token from being sent anywhere
+	// but the authentication endpoints.
+	refreshCookiePath = "/api/v1/authentication"
+	maxUserAgentLen   = 255
+)
+
+type tokenResponse struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// startSession signs the user in on a new device and sets the auth
+// cookies.
+func (app *application) startSession(w http.ResponseWriter, r *http.Request, user *store.User) (tokenResponse, error) {
+	refreshToken, hash, err := auth.NewToken()
+	if err != nil {
+		return tokenResponse{}, err
+	}
+
+	userAgent := r.UserAgent()
+	if len(userAgent) > maxUserAgentLen {
+		userAgent = userAgent[:maxUserAgentLen]
+	}
+
+	session := &store.Session{
+		UserID:    user.ID,
+		UserAgent: userAgent,
+		ExpiresAt: time.Now().Add(app.config.auth.token.refreshExp),
+	}
+	if err := app.store.Sessions.Create(r.Context(), session, hash); err != nil {
+		return tokenResponse{}, err
+	}
+
+	return app.issueTokens(w, user, session, refreshToken)
+}
+
+// issueTokens signs a short-lived access token for the session and sets
+// it and the refresh token as cookies.
+func (app *application) issueTokens(w http.ResponseWriter, user *store.User, session *store.Session, refreshToken string) (tokenResponse, error) {
+	now := time.Now()
+	expiresAt := now.Add(app.config.auth.token.exp)
+
+	claims := jwt.MapClaims{
+		"sub":  user.ID,
+		"sid":  session.ID,
+		"role": user.Role,
+		"exp":  expiresAt.Unix(),
+		"iat":  now.Unix(),
+		"nbf":  now.Unix(),
+		"iss":  app.config.auth.token.iss,
+		"aud":  app.config.auth.token.iss,
+	}
+
+	token, err := app.authenticator.GenerateToken(claims)
+	if err != nil {
+		return tokenResponse{}, err
+	}
+
+	setAuthCookie(w, accessTokenCookie, token, "/", expiresAt)
+	setAuthCookie(w, refreshTokenCookie, refreshToken, refreshCookiePath, session.ExpiresAt)
+
+	w.Header().Set("Access-Control-Allow-Credentials", "true")
+	w.Header().Add("Access-Control-Expose-Headers", "Set-Cookie")
+
+	return tokenResponse{Token: token, RefreshToken: refreshToken}, nil
+}
+
+func setAuthCookie(w http.ResponseWriter, name, value, path string, expires time.Time) {
 	http.SetCookie(w, &http.Cookie{
-		Name:     "jwt",
-		Value:    "",
-		Path:     "/",
-		Expires:  time.Unix(0, 0),
-		MaxAge:   -1,
+		Name:     name,
+		Value:    value,
+		Path:     path,
+		Domain:   "",
 		HttpOnly: true,
 		Secure:   true,
 		SameSite: http.SameSiteNoneMode,
+		Expires:  expires,
 	})
+}
 
-	w.WriteHeader(http.StatusNoContent)
+func clearAuthCookies(w http.ResponseWriter) {
+	for _, cookie := range []struct{ name, path string }{
+		{accessTokenCookie, "/"},
+		{refreshTokenCookie, refreshCookiePath},
+	} {
+		http.SetCookie(w, &http.Cookie{
+			Name:     cookie.name,
+			Value:    "",
+			Path:     cookie.path,
+			Expires:  time.Unix(0, 0),
+			MaxAge:   -1,
+			HttpOnly: true,
+			Secure:   true,
+			SameSite: http.SameSiteNoneMode,
+		})
+	}
 }
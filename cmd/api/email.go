@@ -0,0 +1,267 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+	"ws_practice_1/internal/auth"
+	"ws_practice_1/internal/mailer"
+	"ws_practice_1/internal/store"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const sendEmailTimeout = 30 * time.Second
+
+func newMailer(cfg mailConfig) (mailer.Mailer, error) {
+	switch cfg.backend {
+	case "log":
+		return mailer.NewLogMailer(), nil
+	case "file":
+		return mailer.NewFileMailer(cfg.fileDir), nil
+	case "smtp":
+		if cfg.smtp.host == "" {
+			return nil, fmt.Errorf("the smtp mailer needs SMTP_HOST")
+		}
+		return mailer.NewSMTPMailer(mailer.SMTPConfig{
+			Host:     cfg.smtp.host,
+			Port:     cfg.smtp.port,
+			Username: cfg.smtp.username,
+			Password: cfg.smtp.password,
+			From:     cfg.from,
+		}), nil
+	default:
+		return nil, fmt.Errorf("unknown mailer %q", cfg.backend)
+	}
+}
+
+// sendEmail delivers msg in the background so slow mail servers do not
+// hold up requests, and so response times do not reveal whether an
+// address has an account.
+func (app *application) sendEmail(msg mailer.Message) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), sendEmailTimeout)
+		defer cancel()
+
+		if err := app.mailer.Send(ctx, msg); err != nil {
+			log.Printf("Error sending %q to %s: %v\n", msg.Subject, msg.To, err)
+		}
+	}()
+}
+
+// sendUserToken issues a single-use token for the user and emails them a
+// link to path carrying it.
+func (app *application) sendUserToken(ctx context.Context, user *store.User, purpose, path string, ttl time.Duration, subject, body string) error {
+	token, hash, err := auth.NewToken()
+	if err != nil {
+		return err
+	}
+
+	userToken := &store.UserToken{
+		UserID:    user.ID,
+		Purpose:   purpose,
+		ExpiresAt: time.Now().Add(ttl),
+	}
+	if err := app.store.UserTokens.Create(ctx, userToken, hash); err != nil {
+		return err
+	}
+
+	link := app.config.appURL + path + "?token=" + url.QueryEscape(token)
+
+	app.sendEmail(mailer.Message{
+		To:      user.Email,
+		Subject: subject,
+		Body:    fmt.Sprintf(body, user.Username, link, ttl),
+	})
+
+	return nil
+}
+
+func (app *application) sendVerificationEmail(ctx context.Context, user *store.User) error {
+	return app.sendUserToken(ctx, user, store.TokenPurposeVerifyEmail, "/verify-email", app.config.mail.verifyEmailTTL,
+		"Verify your email address",
+		"Hi %s,\n\nConfirm your email address to play ranked matches:\n\n%s\n\nThe link expires in %s.\n")
+}
+
+func (app *application) sendPasswordResetEmail(ctx context.Context, user *store.User) error {
+	return app.sendUserToken(ctx, user, store.TokenPurposeResetPassword, "/reset-password", app.config.mail.resetPasswordTTL,
+		"Reset your password",
+		"Hi %s,\n\nSomeone asked to reset your password. If it was you, choose a new one here:\n\n%s\n\nThe link expires in %s. If you did not ask for this, you can ignore this email.\n")
+}
+
+type VerifyEmailPayload struct {
+	Token string `json:"token" validate:"required,max=100"`
+}
+
+func (app *application) verifyEmailHandler(w http.ResponseWriter, r *http.Request) {
+	var payload VerifyEmailPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	token, err := app.store.UserTokens.Consume(ctx, auth.HashToken(payload.Token), store.TokenPurposeVerifyEmail)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.badRequestResponse(w, r, fmt.Errorf("verification link is invalid or has expired"))
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.store.Users.VerifyEmail(ctx, token.UserID); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.badRequestResponse(w, r, fmt.Errorf("verification link is invalid or has expired"))
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	user, err := app.store.Users.GetByID(ctx, token.UserID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, user); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+func (app *application) resendVerificationEmailHandler(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value(userCtx).(*store.User)
+
+	if user.EmailVerified() {
+		app.conflictResponse(w, r, fmt.Errorf("email is already verified"))
+		return
+	}
+
+	if err := app.sendVerificationEmail(r.Context(), user); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+type ForgotPasswordPayload struct {
+	Email string `json:"email" validate:"required,email,max=255"`
+}
+
+// forgotPasswordHandler emails a reset link if the address has an account.
+// It answers the same either way so it cannot be used to find accounts.
+func (app *application) forgotPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var payload ForgotPasswordPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user, err := app.store.Users.GetByEmail(r.Context(), payload.Email)
+	switch err {
+	case nil:
+		if err := app.sendPasswordResetEmail(r.Context(), user); err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+	case store.ErrNotFound:
+	default:
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	response := map[string]string{
+		"message": "If an account uses that email, a reset link is on its way.",
+	}
+	if err := app.jsonResponse(w, http.StatusAccepted, response); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+type ResetPasswordPayload struct {
+	Token       string `json:"token" validate:"required,max=100"`
+	NewPassword string `json:"new_password" validate:"required,min=3,max=72"`
+}
+
+// resetPasswordHandler sets a new password from a reset link and signs the
+// user out everywhere. Following the link also proves they own the email.
+func (app *application) resetPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var payload ResetPasswordPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	token, err := app.store.UserTokens.Consume(ctx, auth.HashToken(payload.Token), store.TokenPurposeResetPassword)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.badRequestResponse(w, r, fmt.Errorf("reset link is invalid or has expired"))
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	user, err := app.store.Users.GetByID(ctx, token.UserID)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.badRequestResponse(w, r, fmt.Errorf("reset link is invalid or has expired"))
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(payload.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	user.Password = hashedPassword
+
+	if err := app.store.Users.Update(ctx, user); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.store.Users.VerifyEmail(ctx, user.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if _, err := app.store.Sessions.RevokeAllForUser(ctx, user.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	clearAuthCookies(w)
+	w.WriteHeader(http.StatusNoContent)
+}
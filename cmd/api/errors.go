@@ -22,3 +22,21 @@ func (app *application) unauthorizedErrorResponse(w http.ResponseWriter, r *http
 
 	writeJSONError(w, http.StatusUnauthorized, "unauthorized")
 }
+
+func (app *application) forbiddenResponse(w http.ResponseWriter, r *http.Request, err error) {
+	log.Printf("Forbidden error: %s path:%s error:%s \n", r.Method, r.URL.Path, err.Error())
+
+	writeJSONError(w, http.StatusForbidden, "forbidden")
+}
+
+func (app *application) notFoundResponse(w http.ResponseWriter, r *http.Request, err error) {
+	log.Printf("Not found error: %s path:%s error:%s \n", r.Method, r.URL.Path, err.Error())
+
+	writeJSONError(w, http.StatusNotFound, "not found")
+}
+
+func (app *application) conflictResponse(w http.ResponseWriter, r *http.Request, err error) {
+	log.Printf("Conflict error: %s path:%s error:%s \n", r.Method, r.URL.Path, err.Error())
+
+	writeJSONError(w, http.StatusConflict, err.Error())
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"strings"
+	"time"
+	"ws_practice_1/internal/judge"
+	"ws_practice_1/internal/store"
+)
+
+// testRun summarises running one answer against a question's test cases.
+// Stdout and Stderr come from the last sample that ran. Output from hidden
+// cases is never kept, since code that echoes its input would reveal them.
+// FailedTest is 1-based and 0 when nothing failed.
+type testRun struct {
+	Passed        int
+	Total         int
+	Verdict       string
+	FailedTest    int
+	CompileOutput string
+	Stdout        string
+	Stderr        string
+	Time          float64
+	Memory        int
+	Tests         []testReport
+}
+
+// testReport is what a player may see about one test case. Hidden inputs
+// and outputs are never included, and a hidden case that failed only gets
+// its index and verdict.
+type testReport struct {
+	Index   int     `json:"index"`
+	Verdict string  `json:"verdict"`
+	Status  string  `json:"status,omitempty"`
+	Time    float64 `json:"time,omitempty"`
+	Memory  int     `json:"memory,omitempty"`
+}
+
+func (t testRun) Accepted() bool {
+	return t.Verdict == judge.VerdictAccepted
+}
+
+// Message describes the verdict in a sentence, e.g. "Wrong answer on test 3".
+func (t testRun) Message() string {
+	switch t.Verdict {
+	case judge.VerdictAccepted:
+		return "Accepted"
+	case judge.VerdictCompilationError:
+		return "Compilation error"
+	case judge.VerdictInternalError:
+		return "The judge failed, please submit again"
+	}
+
+	msg := strings.ReplaceAll(t.Verdict, "_", " ")
+	msg = strings.ToUpper(msg[:1]) + msg[1:]
+	if t.FailedTest > 0 {
+		msg += fmt.Sprintf(" on test %d", t.FailedTest)
+	}
+	return msg
+}
+
+// runTestCases runs the code against every test case in order and stops at
+// the first one that fails according to the question's checker.
+func (app *application) runTestCases(ctx context.Context, code string, langID int, question store.DSAQuestion, cases []store.TestCase) (run testRun, err error) {
+	defer recoverJudge(&err)
+
+	run = testRun{Total: len(cases), Tests: []testReport{}}
+	if len(cases) == 0 {
+		return run, fmt.Errorf("question has no test cases")
+	}
+
+	checker := questionChecker(question)
+	timeLimit, memoryLimitKB := app.questionLimits(question)
+
+	for i, tc := range cases {
+		result, err := app.judge.Execute(ctx, judge.Submission{
+			SourceCode:    code,
+			LanguageID:    langID,
+			Stdin:         tc.Input,
+			TimeLimit:     timeLimit,
+			MemoryLimitKB: memoryLimitKB,
+		})
+		if err != nil {
+			return run, err
+		}
+
+		if !tc.IsHidden {
+			run.Stdout = result.Stdout
+			run.Stderr = result.Stderr
+		}
+		run.Time = max(run.Time, result.Time)
+		run.Memory = max(run.Memory, result.Memory)
+
+		report := testReport{
+			Index:   i + 1,
+			Verdict: judge.VerdictFor(result, memoryLimitKB),
+			Status:  result.Status.Description,
+			Time:    result.Time,
+			Memory:  result.Memory,
+		}
+
+		if report.Verdict == judge.VerdictAccepted {
+			ok, err := checker.Check(ctx, app.judge, tc.Input, tc.ExpectedOutput, result.Stdout)
+			if err != nil {
+				return run, err
+			}
+			if !ok {
+				report.Verdict = judge.VerdictWrongAnswer
+			}
+		}
+
+		failed := report.Verdict != judge.VerdictAccepted
+		if failed && tc.IsHidden {
+			report = testReport{Index: report.Index, Verdict: report.Verdict}
+		}
+
+		run.Tests = append(run.Tests, report)
+
+		if failed {
+			run.Verdict = report.Verdict
+			run.FailedTest = report.Index
+			// Compiler output does not depend on the input, unlike
+			// anything printed at runtime.
+			if report.Verdict == judge.VerdictCompilationError {
+				run.CompileOutput = result.CompileOutput
+			}
+			return run, nil
+		}
+
+		run.Passed++
+	}
+
+	run.Verdict = judge.VerdictAccepted
+	return run, nil
+}
+
+// internalErrorRun is reported when the judge itself failed, so the player
+// gets a verdict instead of silence.
+func internalErrorRun(total int) testRun {
+	return testRun{Total: total, Verdict: judge.VerdictInternalError, Tests: []testReport{}}
+}
+
+// runCases executes the code once per case and reports everything the
+// judge returned. When check is set each output is also compared against
+// the expected one. A compilation error ends the run early since every
+// other case would fail the same way.
+func (app *application) runCases(ctx context.Context, code string, langID int, question store.DSAQuestion, cases []store.TestCase, check bool) (result runResult, err error) {
+	defer recoverJudge(&err)
+
+	result = runResult{Cases: []runCase{}}
+	checker := questionChecker(question)
+	timeLimit, memoryLimitKB := app.questionLimits(question)
+
+	for _, tc := range cases {
+		res, err := app.judge.Execute(ctx, judge.Submission{
+			SourceCode:    code,
+			LanguageID:    langID,
+			Stdin:         tc.Input,
+			TimeLimit:     timeLimit,
+			MemoryLimitKB: memoryLimitKB,
+		})
+		if err != nil {
+			return result, err
+		}
+
+		rc := runCase{
+			Input:         tc.Input,
+			Status:        res.Status.Description,
+			Stdout:        res.Stdout,
+			Stderr:        res.Stderr,
+			CompileOutput: res.CompileOutput,
+			Time:          res.Time,
+			Memory:        res.Memory,
+		}
+
+		if check {
+			passed := false
+			if res.Accepted() {
+				passed, err = checker.Check(ctx, app.judge, tc.Input, tc.ExpectedOutput, res.Stdout)
+				if err != nil {
+					return result, err
+				}
+			}
+			rc.Expected = tc.ExpectedOutput
+			rc.Passed = &passed
+		}
+
+		result.Cases = append(result.Cases, rc)
+
+		if res.Status.ID == judge.StatusCompilationError {
+			break
+		}
+	}
+
+	return result, nil
+}
+
+// recoverJudge turns a panic in a judge backend or checker into an error,
+// so the player gets an internal error instead of silence.
+func recoverJudge(err *error) {
+	if r := recover(); r != nil {
+		*err = fmt.Errorf("judge panicked: %v\n%s", r, debug.Stack())
+	}
+}
+
+// questionLimits returns the time and memory limits to judge a question
+// with, falling back to the configured memory limit and the backend's own
+// time limit.
+func (app *application) questionLimits(q store.DSAQuestion) (time.Duration, int) {
+	memoryLimitKB := q.MemoryLimitKB
+	if memoryLimitKB <= 0 {
+		memoryLimitKB = app.config.judge.memoryLimitKB
+	}
+
+	return time.Duration(q.TimeLimitMs) * time.Millisecond, memoryLimitKB
+}
+
+func questionChecker(q store.DSAQuestion) judge.Checker {
+	return judge.Checker{
+		Mode:       q.Checker,
+		Tolerance:  q.Tolerance,
+		Source:     q.CheckerSource,
+		LanguageID: q.CheckerLangID,
+	}
+}
+
+func visibleTestCases(cases []store.TestCase) []store.TestCase {
+	var samples []store.TestCase
+	for _, tc := range cases {
+		if !tc.IsHidden {
+			samples = append(samples, tc)
+		}
+	}
+	return samples
+}
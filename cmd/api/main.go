@@ -6,14 +6,32 @@ import (
 	"ws_practice_1/internal/auth"
 	"ws_practice_1/internal/db"
 	"ws_practice_1/internal/env"
+	"ws_practice_1/internal/judge"
+	"ws_practice_1/internal/matchmaking"
+	"ws_practice_1/internal/oauth"
 	"ws_practice_1/internal/store"
-
-	"github.com/gorilla/websocket"
 )
 
 func main() {
 	port := env.GetString("PORT", "8080")
 	addr := ":" + port
+
+	// Only development falls back to the local frontend; production must be
+	// told where its frontend lives.
+	environment := env.GetString("ENV", "production")
+	allowedOrigins := []string{"http://localhost:5173"}
+	appURL := "http://localhost:5173"
+	if environment == "production" {
+		allowedOrigins, appURL = nil, ""
+	}
+
+	// Emails carry sign-in links, so outside development they are only ever
+	// sent, never logged.
+	mailBackend, smtpHost := "log", "localhost"
+	if environment == "production" {
+		mailBackend, smtpHost = "smtp", ""
+	}
+
 	cfg := config{
 		addr: addr,
 		db: dbConfig{
@@ -27,18 +45,94 @@ func main() {
 			maxIdleConns: env.GetInt("DB_MAX_IDLE_CONNS", 30),
 			maxIdleTime:  env.GetString("DB_MAX_IDLE_TIME", "15m"),
 		},
-		env:    env.GetString("ENV", "production"),
+		env:    environment,
 		apiURL: env.GetString("EXTERNAL_URL", "localhost:8080"),
+		appURL: env.GetString("APP_URL", appURL),
 		auth: authConfig{
-			basic: basicConfig{
-				user: env.GetString("AUTH_BASIC_USER", "admin"),
-				pass: env.GetString("AUTH_BASIC_PASS", "admin"),
-			},
 			token: tokenConfig{
-				secret: env.GetString("AUTH_TOKEN_SECRET", "example"),
-				exp:    time.Hour * 24 * 3,
-				iss:    "ws1",
+				secret:     env.GetString("AUTH_TOKEN_SECRET", "example"),
+				exp:        env.GetDuration("AUTH_TOKEN_EXP", 15*time.Minute),
+				refreshExp: env.GetDuration("AUTH_REFRESH_TOKEN_EXP", 30*24*time.Hour),
+				iss:        "ws1",
 			},
+			wsTicketTTL: env.GetDuration("WS_TICKET_TTL", 30*time.Second),
+		},
+		cors: corsConfig{
+			allowedOrigins: env.GetStrings("CORS_ALLOWED_ORIGINS", allowedOrigins),
+		},
+		mail: mailConfig{
+			backend: env.GetString("MAILER", mailBackend),
+			from:    env.GetString("MAIL_FROM", "no-reply@localhost"),
+			fileDir: env.GetString("MAIL_FILE_DIR", "mail"),
+			smtp: smtpConfig{
+				host:     env.GetString("SMTP_HOST", smtpHost),
+				port:     env.GetInt("SMTP_PORT", 587),
+				username: env.GetString("SMTP_USERNAME", ""),
+				password: env.GetString("SMTP_PASSWORD", ""),
+			},
+			verifyEmailTTL:   env.GetDuration("EMAIL_VERIFY_TOKEN_TTL", 24*time.Hour),
+			resetPasswordTTL: env.GetDuration("PASSWORD_RESET_TOKEN_TTL", time.Hour),
+		},
+		oauth: oauthConfig{
+			callbackURL: env.GetString("OAUTH_CALLBACK_URL", "http://localhost:8080/api/v1/authentication/oauth"),
+			flowTTL:     env.GetDuration("OAUTH_FLOW_TTL", 10*time.Minute),
+			providers: map[string]oauth.Config{
+				"github": {
+					ClientID:     env.GetString("GITHUB_CLIENT_ID", ""),
+					ClientSecret: env.GetString("GITHUB_CLIENT_SECRET", ""),
+				},
+				"google": {
+					ClientID:     env.GetString("GOOGLE_CLIENT_ID", ""),
+					ClientSecret: env.GetString("GOOGLE_CLIENT_SECRET", ""),
+					Issuer:       "https://accounts.google.com",
+				},
+				// Any other OpenID Connect provider, such as a local one
+				// for development.
+				"oidc": {
+					ClientID:     env.GetString("OIDC_CLIENT_ID", ""),
+					ClientSecret: env.GetString("OIDC_CLIENT_SECRET", ""),
+					Issuer:       env.GetString("OIDC_ISSUER", ""),
+				},
+			},
+		},
+		judge: judgeConfig{
+			backend: judge.Config{
+				Backend:   env.GetString("JUDGE_BACKEND", "rapidapi"),
+				URL:       env.GetString("JUDGE0_URL", "http://localhost:2358"),
+				AuthToken: env.GetString("JUDGE0_AUTH_TOKEN", ""),
+				APIKey:    env.GetString("RAPID_API_KEY", ""),
+				APIHost:   env.GetString("RAPID_API_HOST", ""),
+				Timeout:   env.GetDuration("JUDGE_TIMEOUT", 30*time.Second),
+				Local: judge.LocalConfig{
+					WorkDir:       env.GetString("JUDGE_LOCAL_WORK_DIR", ""),
+					TimeLimit:     env.GetDuration("JUDGE_LOCAL_TIME_LIMIT", 2*time.Second),
+					MemoryLimitKB: env.GetInt("JUDGE_LOCAL_MEMORY_LIMIT_KB", 256*1024),
+				},
+			},
+			memoryLimitKB: env.GetInt("JUDGE_MEMORY_LIMIT_KB", 256*1024),
+			pool: judgePoolConfig{
+				workers:   env.GetInt("JUDGE_WORKERS", 4),
+				queueSize: env.GetInt("JUDGE_QUEUE_SIZE", 64),
+				perUser:   env.GetInt("JUDGE_MAX_IN_FLIGHT_PER_USER", 1),
+				timeout:   env.GetDuration("JUDGE_SUBMISSION_TIMEOUT", 2*time.Minute),
+			},
+		},
+		queue: queueConfig{
+			initialWindow:  env.GetInt("QUEUE_INITIAL_WINDOW", 100),
+			windowGrowth:   env.GetInt("QUEUE_WINDOW_GROWTH", 50),
+			growthInterval: env.GetDuration("QUEUE_GROWTH_INTERVAL", 5*time.Second),
+			maxWindow:      env.GetInt("QUEUE_MAX_WINDOW", 0),
+			tick:           env.GetPositiveDuration("QUEUE_TICK", time.Second),
+		},
+		rooms: roomConfig{
+			ttl: env.GetDuration("ROOM_TTL", time.Hour),
+		},
+		match: matchConfig{
+			duration:             env.GetDuration("MATCH_DURATION", 30*time.Minute),
+			tick:                 env.GetPositiveDuration("MATCH_TICK", 10*time.Second),
+			reconnectGrace:       env.GetDuration("MATCH_RECONNECT_GRACE", 30*time.Second),
+			idleAfter:            env.GetDuration("MATCH_IDLE_AFTER", 10*time.Second),
+			questionRatingWindow: env.GetInt("MATCH_QUESTION_RATING_WINDOW", 200),
 		},
 	}
 
@@ -63,18 +157,54 @@ func main() {
 
 	jwtAuthenticator := auth.NewJWTAuthenticator(cfg.auth.token.secret, cfg.auth.token.iss, cfg.auth.token.iss)
 
+	codeJudge, err := judge.New(cfg.judge.backend)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Using %s judge backend\n", cfg.judge.backend.Backend)
+
+	if cfg.env == "production" && len(cfg.cors.allowedOrigins) == 0 {
+		log.Fatal("CORS_ALLOWED_ORIGINS must be set in production")
+	}
+	if cfg.env == "production" && cfg.appURL == "" {
+		log.Fatal("APP_URL must be set in production")
+	}
+
+	if cfg.env == "production" && cfg.mail.backend != "smtp" {
+		log.Fatalf("MAILER must be smtp in production, not %q", cfg.mail.backend)
+	}
+
+	mailer, err := newMailer(cfg.mail)
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	app := &application{
 		config:        cfg,
 		store:         store,
 		authenticator: jwtAuthenticator,
+		judge:         codeJudge,
+		wsTickets:     auth.NewTickets(cfg.auth.wsTicketTTL),
+		mailer:        mailer,
+		oauth:         newOAuthProviders(cfg.oauth),
+		oauthFlows:    oauth.NewFlows(cfg.oauth.flowTTL),
 	}
 
-	app.ws = wsApp{
-		matchMaking: MatchMaking{},
-		matches:     make(map[*websocket.Conn]*Match),
-		scores:      make(map[*websocket.Conn]int),
-		app:         app,
-	}
+	app.judgePool = judge.NewPool(judge.PoolConfig{
+		Workers:   cfg.judge.pool.workers,
+		QueueSize: cfg.judge.pool.queueSize,
+		PerUser:   cfg.judge.pool.perUser,
+		Timeout:   cfg.judge.pool.timeout,
+	})
+
+	app.ws = newWSApp(app, matchmaking.NewQueue(matchmaking.Config{
+		InitialWindow:  cfg.queue.initialWindow,
+		WindowGrowth:   cfg.queue.windowGrowth,
+		GrowthInterval: cfg.queue.growthInterval,
+		MaxWindow:      cfg.queue.maxWindow,
+	}))
+
+	go app.ws.runMatchmaker(cfg.queue.tick, cfg.queue.growthInterval)
 
 	mux := app.mount()
 	log.Fatal(app.run(mux))
@@ -0,0 +1,838 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sync"
+	"time"
+	"ws_practice_1/internal/judge"
+	"ws_practice_1/internal/rating"
+	"ws_practice_1/internal/store"
+)
+
+type Match struct {
+	ID          int64
+	Player1     *store.User
+	Player2     *store.User
+	Question    store.DSAQuestion
+	TestCases   []store.TestCase
+	Settings    matchSettings
+	Deadline    time.Time
+	Scores      map[int64]int
+	Submissions map[int64][]matchSubmission
+	Typing      map[int64]bool
+	IsCompleted bool
+	done        chan struct{}
+	graceTimers map[int64]*time.Timer
+	lastTyped   map[int64]time.Time
+	mu          sync.Mutex
+}
+
+// complete marks the match as over and stops its clock and any pending
+// forfeits. Callers must hold m.mu and check IsCompleted first.
+func (m *Match) complete() {
+	m.IsCompleted = true
+	close(m.done)
+
+	for userID, timer := range m.graceTimers {
+		timer.Stop()
+		delete(m.graceTimers, userID)
+	}
+}
+
+func (m *Match) opponentOf(userID int64) *store.User {
+	if m.Player1.ID == userID {
+		return m.Player2
+	}
+	return m.Player1
+}
+
+// matchSettings carries the options the players queued with or a private
+// room was created with. Only ranked matches change ratings.
+type matchSettings struct {
+	Mode       string
+	RoomCode   string
+	Difficulty string
+	Tag        string
+	TimeLimit  time.Duration
+}
+
+func (s matchSettings) rated() bool {
+	return s.Mode == modeRanked
+}
+
+type matchSubmission struct {
+	ID          int64     `json:"id,omitempty"`
+	LanguageID  int       `json:"language_id"`
+	Answer      string    `json:"answer"`
+	Verdict     string    `json:"verdict"`
+	TestsPassed int       `json:"tests_passed"`
+	TotalTests  int       `json:"total_tests"`
+	SubmittedAt time.Time `json:"submitted_at"`
+}
+
+type ratingDelta struct {
+	Before int `json:"before"`
+	After  int `json:"after"`
+	Delta  int `json:"delta"`
+}
+
+func opponentInfo(user *store.User) *Opponent {
+	return &Opponent{
+		Username:        user.Username,
+		Points:          int(math.Round(user.Rating)),
+		RatingDeviation: int(math.Round(user.RatingDeviation)),
+	}
+}
+
+// errPlayerUnavailable means a player left or started another match before
+// theirs could begin.
+var errPlayerUnavailable = errors.New("player is no longer available")
+
+// startMatch picks a question and sends it to both players. It returns
+// errPlayerUnavailable if either of them is no longer connected or already
+// playing, and a wsError if no question fits the settings. Nothing is sent
+// to the players when it fails; see sendMatchError.
+func (app *wsApp) startMatch(currentUserID, waitingUserID int64, settings matchSettings) error {
+	if !app.reserve(currentUserID, waitingUserID) {
+		return errPlayerUnavailable
+	}
+	registered := false
+	defer func() {
+		if !registered {
+			app.release(currentUserID, waitingUserID)
+		}
+	}()
+
+	ctx := context.Background()
+
+	// Ratings change after every match and sockets now outlive them, so
+	// the users cached on the clients may be stale.
+	player1, err := app.app.store.Users.GetByID(ctx, currentUserID)
+	if err != nil {
+		return fmt.Errorf("fetching player: %w", err)
+	}
+	player2, err := app.app.store.Users.GetByID(ctx, waitingUserID)
+	if err != nil {
+		return fmt.Errorf("fetching player: %w", err)
+	}
+
+	// Rated rooms bypass the queue's check, so unverified players can
+	// still reach a rated match through one; play it casual instead.
+	if settings.rated() && (!player1.EmailVerified() || !player2.EmailVerified()) {
+		settings.Mode = modeCasual
+	}
+
+	question, err := app.app.store.Questions.Pick(ctx, store.QuestionCriteria{
+		Rating:        int(math.Round((player1.Rating + player2.Rating) / 2)),
+		RatingWindow:  app.app.config.match.questionRatingWindow,
+		Difficulty:    settings.Difficulty,
+		Tag:           settings.Tag,
+		ExcludeSeenBy: []int64{currentUserID, waitingUserID},
+	})
+	if err != nil {
+		if err == store.ErrNotFound {
+			return newWSError(errCodeNoQuestion, "No question matches the match settings.")
+		}
+		return fmt.Errorf("picking question: %w", err)
+	}
+
+	testCases, err := app.app.store.Questions.GetTestCases(ctx, question.ID)
+	if err != nil {
+		return fmt.Errorf("fetching test cases: %w", err)
+	}
+	question.Samples = visibleTestCases(testCases)
+
+	duration := settings.TimeLimit
+	if duration <= 0 {
+		duration = app.app.config.match.duration
+	}
+
+	match := &Match{
+		Player1:     player1,
+		Player2:     player2,
+		Question:    *question,
+		TestCases:   testCases,
+		Settings:    settings,
+		Deadline:    time.Now().Add(duration),
+		Scores:      map[int64]int{currentUserID: 0, waitingUserID: 0},
+		Submissions: make(map[int64][]matchSubmission),
+		Typing:      make(map[int64]bool),
+		IsCompleted: false,
+		done:        make(chan struct{}),
+		graceTimers: make(map[int64]*time.Timer),
+		lastTyped:   make(map[int64]time.Time),
+	}
+
+	record := store.Match{
+		Player1ID:  currentUserID,
+		Player2ID:  waitingUserID,
+		QuestionID: question.ID,
+	}
+	if err := app.app.store.Matches.Create(ctx, &record); err != nil {
+		return fmt.Errorf("creating match: %w", err)
+	}
+	match.ID = record.ID
+
+	// The reservation is swapped for the match in one step, so the players
+	// are never free in between.
+	app.mu.Lock()
+	app.matches[match.ID] = match
+	app.userMatches[currentUserID] = match.ID
+	app.userMatches[waitingUserID] = match.ID
+	delete(app.starting, currentUserID)
+	delete(app.starting, waitingUserID)
+	var gone []int64
+	for _, userID := range []int64{currentUserID, waitingUserID} {
+		if app.clients[userID] == nil {
+			gone = append(gone, userID)
+		}
+	}
+	app.mu.Unlock()
+	registered = true
+
+	log.Printf("Matched users %d and %d in match %d\n", currentUserID, waitingUserID, match.ID)
+
+	app.sendToUser(currentUserID, newEvent(evtMatched, newMatchedEvent(match, player2)))
+	app.sendToUser(waitingUserID, newEvent(evtMatched, newMatchedEvent(match, player1)))
+
+	questionMsg := newEvent(evtQuestion, questionEvent{MatchID: match.ID, Question: *question})
+	app.sendToUser(currentUserID, questionMsg)
+	app.sendToUser(waitingUserID, questionMsg)
+
+	go app.runMatchClock(match)
+
+	// Whoever disconnected while the match was being set up gets the same
+	// grace period as during it.
+	for _, userID := range gone {
+		app.startGracePeriod(match, userID)
+	}
+
+	return nil
+}
+
+// reserve marks both players as starting a match if they are connected and
+// not already playing or starting one. It reports whether it did.
+func (app *wsApp) reserve(userIDs ...int64) bool {
+	app.mu.Lock()
+	defer app.mu.Unlock()
+
+	for _, userID := range userIDs {
+		_, playing := app.userMatches[userID]
+		if app.clients[userID] == nil || playing || app.starting[userID] {
+			return false
+		}
+	}
+	for _, userID := range userIDs {
+		app.starting[userID] = true
+	}
+	return true
+}
+
+// release frees players whose match could not be started.
+func (app *wsApp) release(userIDs ...int64) {
+	app.mu.Lock()
+	defer app.mu.Unlock()
+
+	for _, userID := range userIDs {
+		delete(app.starting, userID)
+	}
+}
+
+// sendMatchError tells the players why their match did not start. Errors
+// that are not meant for players are reported as internal errors.
+func (app *wsApp) sendMatchError(err error, userIDs ...int64) {
+	wsErr, ok := err.(*wsError)
+	if !ok {
+		wsErr = newWSError(errCodeInternal, "The match could not be started.")
+	}
+
+	for _, userID := range userIDs {
+		app.sendToUser(userID, newEvent(evtError, wsErr))
+	}
+}
+
+func newMatchedEvent(match *Match, opponent *store.User) matchedEvent {
+	return matchedEvent{
+		MatchID:     match.ID,
+		Opponent:    opponentInfo(opponent),
+		Mode:        match.Settings.Mode,
+		Difficulty:  match.Settings.Difficulty,
+		Tag:         match.Settings.Tag,
+		SecondsLeft: secondsUntil(match.Deadline),
+		RoomCode:    match.Settings.RoomCode,
+	}
+}
+
+// runMatchClock broadcasts the time left until the match ends one way or
+// another, and settles it when the deadline passes.
+func (app *wsApp) runMatchClock(match *Match) {
+	ticker := time.NewTicker(app.app.config.match.tick)
+	defer ticker.Stop()
+
+	timer := time.NewTimer(time.Until(match.Deadline))
+	defer timer.Stop()
+
+	idleAfter := app.app.config.match.idleAfter
+	idle := time.NewTicker(max(idleAfter/2, time.Second))
+	defer idle.Stop()
+
+	app.broadcastTimeRemaining(match)
+
+	for {
+		select {
+		case <-match.done:
+			return
+		case <-ticker.C:
+			app.broadcastTimeRemaining(match)
+		case <-idle.C:
+			app.markIdle(match, idleAfter)
+		case <-timer.C:
+			app.expireMatch(match)
+			return
+		}
+	}
+}
+
+func secondsUntil(deadline time.Time) int {
+	left := time.Until(deadline).Round(time.Second)
+	if left < 0 {
+		return 0
+	}
+	return int(left.Seconds())
+}
+
+func (app *wsApp) broadcastTimeRemaining(match *Match) {
+	msg := newEvent(evtTimeRemaining, timeRemaining{SecondsLeft: secondsUntil(match.Deadline)})
+	app.sendToUser(match.Player1.ID, msg)
+	app.sendToUser(match.Player2.ID, msg)
+}
+
+// activeMatch returns the user's running match, or a protocol error if
+// they are not playing or the match has already finished.
+func (app *wsApp) activeMatch(userID int64) (*Match, error) {
+	app.mu.Lock()
+	match := app.matches[app.userMatches[userID]]
+	app.mu.Unlock()
+
+	if match == nil {
+		return nil, newWSError(errCodeNotInMatch, "You are not in a match.")
+	}
+
+	match.mu.Lock()
+	defer match.mu.Unlock()
+	if match.IsCompleted {
+		return nil, newWSError(errCodeNotInMatch, "The match is already over.")
+	}
+
+	return match, nil
+}
+
+// submitAnswer hands an answer to the judge pool and returns straight away,
+// so a slow judge never blocks the player's socket. The player gets a
+// "judging" event when a worker picks it up and a "verdict" when done.
+func (app *wsApp) submitAnswer(c *client, replyTo string, data submitCommand) error {
+	userID := c.user.ID
+
+	match, err := app.activeMatch(userID)
+	if err != nil {
+		return err
+	}
+
+	err = app.app.judgePool.Submit(userID, func(ctx context.Context) {
+		app.judgeAnswer(ctx, match, userID, replyTo, data)
+	})
+	if err != nil {
+		return judgeSubmitError(err)
+	}
+
+	app.sendProgress(match, userID, progressSubmitted, -1)
+
+	return nil
+}
+
+// runCode runs an answer against the question's visible samples, or the
+// player's own stdin, so they can try it out. Nothing is recorded and the
+// match is unaffected.
+func (app *wsApp) runCode(c *client, replyTo string, data runCommand) error {
+	userID := c.user.ID
+
+	match, err := app.activeMatch(userID)
+	if err != nil {
+		return err
+	}
+
+	cases := visibleTestCases(match.TestCases)
+	if data.Stdin != nil {
+		cases = []store.TestCase{{Input: *data.Stdin}}
+	}
+
+	return judgeSubmitError(app.app.judgePool.Submit(userID, func(ctx context.Context) {
+		app.sendToUser(userID, replyEvent(replyTo, evtJudging, judgingStatus{LanguageID: data.LanguageID, TotalTests: len(cases)}))
+
+		result, err := app.app.runCases(ctx, data.SourceCode, data.LanguageID, match.Question, cases, data.Stdin == nil)
+		if err != nil {
+			log.Printf("Judge error for user %d: %v\n", userID, err)
+			app.sendToUser(userID, replyEvent(replyTo, evtError, newWSError(errCodeJudgeFailed, "Your code could not be run.")))
+			return
+		}
+
+		app.sendToUser(userID, replyEvent(replyTo, evtRunResult, result))
+	}))
+}
+
+// runSample runs an answer against the question's visible samples for
+// clients that still send run_sample.
+func (app *wsApp) runSample(c *client, replyTo string, data submitCommand) error {
+	userID := c.user.ID
+
+	match, err := app.activeMatch(userID)
+	if err != nil {
+		return err
+	}
+
+	return judgeSubmitError(app.app.judgePool.Submit(userID, func(ctx context.Context) {
+		samples := visibleTestCases(match.TestCases)
+		app.sendToUser(userID, replyEvent(replyTo, evtJudging, judgingStatus{LanguageID: data.LanguageID, TotalTests: len(samples)}))
+
+		run, err := app.app.runTestCases(ctx, data.SourceCode, data.LanguageID, match.Question, samples)
+		if err != nil {
+			log.Printf("Judge error for user %d: %v\n", userID, err)
+			app.sendToUser(userID, replyEvent(replyTo, evtError, newWSError(errCodeJudgeFailed, "Your code could not be run.")))
+			return
+		}
+
+		app.sendToUser(userID, replyEvent(replyTo, evtSampleResult, sampleResult{
+			Verdict:     run.Verdict,
+			TestsPassed: run.Passed,
+			TotalTests:  run.Total,
+			Stdout:      run.Stdout,
+			Stderr:      run.Stderr,
+		}))
+	}))
+}
+
+func judgeSubmitError(err error) error {
+	switch err {
+	case nil:
+		return nil
+	case judge.ErrTooManyInFlight:
+		return newWSError(errCodeTooManySubmissions, "Wait for your previous submission to be judged.")
+	case judge.ErrPoolBusy:
+		return newWSError(errCodeJudgeBusy, "The judge is busy, try again in a moment.")
+	default:
+		return err
+	}
+}
+
+func (app *wsApp) judgeAnswer(ctx context.Context, match *Match, userID int64, replyTo string, data submitCommand) {
+	match.mu.Lock()
+	completed := match.IsCompleted
+	match.mu.Unlock()
+	if completed {
+		return
+	}
+
+	app.sendToUser(userID, replyEvent(replyTo, evtJudging, judgingStatus{LanguageID: data.LanguageID, TotalTests: len(match.TestCases)}))
+
+	run, err := app.app.runTestCases(ctx, data.SourceCode, data.LanguageID, match.Question, match.TestCases)
+	if err != nil {
+		log.Printf("Judge error for user %d: %v\n", userID, err)
+		run = internalErrorRun(len(match.TestCases))
+	}
+
+	sub := app.app.saveSubmission(match, userID, data, run)
+
+	app.sendToUser(userID, replyEvent(replyTo, evtVerdict, verdict{
+		SubmissionID:  sub.ID,
+		Verdict:       run.Verdict,
+		Message:       run.Message(),
+		TestsPassed:   run.Passed,
+		TotalTests:    run.Total,
+		FailedTest:    run.FailedTest,
+		CompileOutput: run.CompileOutput,
+		Time:          run.Time,
+		Memory:        run.Memory,
+		Tests:         run.Tests,
+	}))
+	app.sendProgress(match, userID, progressJudged, run.Passed)
+
+	match.mu.Lock()
+	if run.Passed > match.Scores[userID] {
+		match.Scores[userID] = run.Passed
+	}
+	match.Submissions[userID] = append(match.Submissions[userID], matchSubmission{
+		ID:          sub.ID,
+		LanguageID:  data.LanguageID,
+		Answer:      data.SourceCode,
+		Verdict:     run.Verdict,
+		TestsPassed: run.Passed,
+		TotalTests:  run.Total,
+		SubmittedAt: sub.CreatedAt,
+	})
+
+	if !run.Accepted() || match.IsCompleted {
+		match.mu.Unlock()
+		return
+	}
+	match.complete()
+	match.mu.Unlock()
+
+	log.Printf("User %d solved match %d\n", userID, match.ID)
+	app.endMatch(match, userID, endReasonSolved)
+}
+
+// noteTyping records that the player is editing their code and tells the
+// opponent if they were not already known to be typing.
+func (app *wsApp) noteTyping(userID int64) error {
+	match, err := app.activeMatch(userID)
+	if err != nil {
+		return err
+	}
+
+	match.mu.Lock()
+	match.lastTyped[userID] = time.Now()
+	wasTyping := match.Typing[userID]
+	match.Typing[userID] = true
+	match.mu.Unlock()
+
+	if !wasTyping {
+		app.sendProgress(match, userID, progressTyping, -1)
+	}
+
+	return nil
+}
+
+// markIdle flips players who stopped sending typing updates back to idle.
+func (app *wsApp) markIdle(match *Match, idleAfter time.Duration) {
+	var idle []int64
+
+	match.mu.Lock()
+	for userID, typing := range match.Typing {
+		if typing && time.Since(match.lastTyped[userID]) >= idleAfter {
+			match.Typing[userID] = false
+			idle = append(idle, userID)
+		}
+	}
+	match.mu.Unlock()
+
+	for _, userID := range idle {
+		app.sendProgress(match, userID, progressIdle, -1)
+	}
+}
+
+// sendProgress tells the opponent of userID what they are doing. A
+// negative passed count means the player's best score so far.
+func (app *wsApp) sendProgress(match *Match, userID int64, status string, passed int) {
+	if passed < 0 {
+		match.mu.Lock()
+		passed = match.Scores[userID]
+		match.mu.Unlock()
+	}
+
+	app.sendToUser(match.opponentOf(userID).ID, newEvent(evtOpponentProgress, opponentProgress{
+		Status:      status,
+		TestsPassed: passed,
+		TotalTests:  len(match.TestCases),
+	}))
+}
+
+// expireMatch ends a match nobody solved in time. The player who passed
+// more test cases wins; equal progress is a draw.
+func (app *wsApp) expireMatch(match *Match) {
+	match.mu.Lock()
+	if match.IsCompleted {
+		match.mu.Unlock()
+		return
+	}
+	match.complete()
+	score1 := match.Scores[match.Player1.ID]
+	score2 := match.Scores[match.Player2.ID]
+	match.mu.Unlock()
+
+	var winnerID int64
+	switch {
+	case score1 > score2:
+		winnerID = match.Player1.ID
+	case score2 > score1:
+		winnerID = match.Player2.ID
+	}
+
+	log.Printf("Match %d timed out (%d vs %d tests)\n", match.ID, score1, score2)
+	app.endMatch(match, winnerID, endReasonTimeout)
+}
+
+// startGracePeriod gives a disconnected player time to reconnect before
+// the match is forfeited to their opponent.
+func (app *wsApp) startGracePeriod(match *Match, userID int64) {
+	grace := app.app.config.match.reconnectGrace
+
+	match.mu.Lock()
+	if match.IsCompleted {
+		match.mu.Unlock()
+		return
+	}
+	if timer, exists := match.graceTimers[userID]; exists {
+		timer.Stop()
+	}
+	match.graceTimers[userID] = time.AfterFunc(grace, func() {
+		app.forfeit(match, userID)
+	})
+	match.mu.Unlock()
+
+	log.Printf("User %d disconnected from match %d, waiting %s for them to return\n", userID, match.ID, grace)
+
+	app.sendToUser(match.opponentOf(userID).ID, newEvent(evtOpponentDisconnected, reconnectWindow{SecondsLeft: int(grace.Seconds())}))
+}
+
+// forfeit ends the match after a player's grace period ran out. If the
+// opponent is also gone by then, nobody wins.
+func (app *wsApp) forfeit(match *Match, userID int64) {
+	opponentID := match.opponentOf(userID).ID
+
+	match.mu.Lock()
+	if _, waiting := match.graceTimers[userID]; match.IsCompleted || !waiting {
+		match.mu.Unlock()
+		return
+	}
+	_, opponentGone := match.graceTimers[opponentID]
+	match.complete()
+	match.mu.Unlock()
+
+	winnerID := opponentID
+	if opponentGone {
+		winnerID = 0
+	}
+
+	log.Printf("User %d did not return to match %d\n", userID, match.ID)
+	app.endMatch(match, winnerID, endReasonDisconnect)
+}
+
+// resign concedes the user's running match to their opponent.
+func (app *wsApp) resign(userID int64) error {
+	match, err := app.activeMatch(userID)
+	if err != nil {
+		return err
+	}
+
+	match.mu.Lock()
+	if match.IsCompleted {
+		match.mu.Unlock()
+		return newWSError(errCodeNotInMatch, "The match is already over.")
+	}
+	match.complete()
+	match.mu.Unlock()
+
+	log.Printf("User %d forfeited match %d\n", userID, match.ID)
+	app.endMatch(match, match.opponentOf(userID).ID, endReasonForfeit)
+
+	return nil
+}
+
+// endMatch settles a completed match and tells both players how it ended.
+// winnerID is 0 for a draw.
+func (app *wsApp) endMatch(match *Match, winnerID int64, reason string) {
+	deltas := app.settleMatch(match, winnerID)
+	endedAt := time.Now()
+
+	match.mu.Lock()
+	scores := map[int64]int{
+		match.Player1.ID: match.Scores[match.Player1.ID],
+		match.Player2.ID: match.Scores[match.Player2.ID],
+	}
+	match.mu.Unlock()
+
+	for _, player := range []*store.User{match.Player1, match.Player2} {
+		result := resultLoss
+		switch winnerID {
+		case 0:
+			result = resultDraw
+		case player.ID:
+			result = resultWin
+		}
+
+		app.sendToUser(player.ID, newEvent(evtMatchEnd, matchEnd{
+			MatchID:             match.ID,
+			Result:              result,
+			Reason:              reason,
+			TestsPassed:         scores[player.ID],
+			OpponentTestsPassed: scores[match.opponentOf(player.ID).ID],
+			TotalTests:          len(match.TestCases),
+			EndedAt:             endedAt,
+			Rating:              deltas[player.ID],
+		}))
+	}
+}
+
+// resume re-attaches a reconnecting player to their running match and
+// sends them its current state. It reports whether there was one.
+func (app *wsApp) resume(c *client) bool {
+	userID := c.user.ID
+
+	app.mu.Lock()
+	match := app.matches[app.userMatches[userID]]
+	app.mu.Unlock()
+
+	if match == nil {
+		return false
+	}
+
+	opponent := match.opponentOf(userID)
+
+	match.mu.Lock()
+	if match.IsCompleted {
+		match.mu.Unlock()
+		return false
+	}
+	if timer, exists := match.graceTimers[userID]; exists {
+		timer.Stop()
+		delete(match.graceTimers, userID)
+	}
+	state := matchState{
+		MatchID:     match.ID,
+		Opponent:    opponentInfo(opponent),
+		Question:    match.Question,
+		SecondsLeft: secondsUntil(match.Deadline),
+		Submissions: append([]matchSubmission{}, match.Submissions[userID]...),
+	}
+	match.mu.Unlock()
+
+	log.Printf("User %d rejoined match %d\n", userID, match.ID)
+
+	c.send(newEvent(evtMatchState, state))
+	app.sendToUser(opponent.ID, newEvent(evtOpponentReconnected, opponentReconnected{Username: c.user.Username}))
+
+	return true
+}
+
+// settleMatch forgets a completed match and records its result. The
+// returned deltas are nil if storing the result failed.
+func (app *wsApp) settleMatch(match *Match, winnerID int64) map[int64]*ratingDelta {
+	app.mu.Lock()
+	delete(app.matches, match.ID)
+	for _, userID := range []int64{match.Player1.ID, match.Player2.ID} {
+		if app.userMatches[userID] == match.ID {
+			delete(app.userMatches, userID)
+		}
+	}
+	app.mu.Unlock()
+
+	deltas, err := app.app.recordMatchResult(match.ID, match.Player1.ID, match.Player2.ID, winnerID, match.Question.ID, match.Settings.rated())
+	if err != nil {
+		log.Println("Error storing match result:", err)
+	}
+
+	return deltas
+}
+
+// recordMatchResult rates the match with Glicko-2 and stores its result
+// along with both players' new ratings. winnerID is 0 for a draw. Unrated
+// matches only store the result and return no deltas.
+func (app *application) recordMatchResult(matchID, player1ID, player2ID, winnerID, questionID int64, rated bool) (map[int64]*ratingDelta, error) {
+	ctx := context.Background()
+
+	matchResult := store.Match{
+		ID:         matchID,
+		Player1ID:  player1ID,
+		Player2ID:  player2ID,
+		QuestionID: questionID,
+	}
+	if winnerID != 0 {
+		matchResult.WinnerID = &winnerID
+	}
+
+	if !rated {
+		return nil, app.store.Ratings.RecordMatch(ctx, &matchResult, nil)
+	}
+
+	player1, err := app.store.Users.GetByID(ctx, player1ID)
+	if err != nil {
+		return nil, err
+	}
+
+	player2, err := app.store.Users.GetByID(ctx, player2ID)
+	if err != nil {
+		return nil, err
+	}
+
+	score := rating.Draw
+	switch winnerID {
+	case player1ID:
+		score = rating.Win
+	case player2ID:
+		score = rating.Loss
+	}
+
+	before1 := userRating(player1)
+	before2 := userRating(player2)
+	after1, after2 := rating.Game(before1, before2, score)
+
+	changes := []store.RatingChange{
+		ratingChange(player1ID, before1, after1),
+		ratingChange(player2ID, before2, after2),
+	}
+
+	if err := app.store.Ratings.RecordMatch(ctx, &matchResult, changes); err != nil {
+		return nil, err
+	}
+
+	deltas := make(map[int64]*ratingDelta, len(changes))
+	for _, c := range changes {
+		before := int(math.Round(c.RatingBefore))
+		after := int(math.Round(c.RatingAfter))
+		deltas[c.UserID] = &ratingDelta{Before: before, After: after, Delta: after - before}
+	}
+
+	return deltas, nil
+}
+
+func userRating(user *store.User) rating.Rating {
+	return rating.Rating{
+		Rating:     user.Rating,
+		Deviation:  user.RatingDeviation,
+		Volatility: user.RatingVolatility,
+	}
+}
+
+func ratingChange(userID int64, before, after rating.Rating) store.RatingChange {
+	return store.RatingChange{
+		UserID:           userID,
+		RatingBefore:     before.Rating,
+		RatingAfter:      after.Rating,
+		DeviationBefore:  before.Deviation,
+		DeviationAfter:   after.Deviation,
+		VolatilityBefore: before.Volatility,
+		VolatilityAfter:  after.Volatility,
+	}
+}
+
+// saveSubmission records an answer attempt for later review. Failing to
+// store it must not cost the player the match, so errors are only logged.
+func (app *application) saveSubmission(match *Match, userID int64, data submitCommand, run testRun) *store.Submission {
+	sub := &store.Submission{
+		MatchID:       match.ID,
+		UserID:        userID,
+		QuestionID:    match.Question.ID,
+		LanguageID:    data.LanguageID,
+		SourceCode:    data.SourceCode,
+		Verdict:       run.Verdict,
+		TestsPassed:   run.Passed,
+		TotalTests:    run.Total,
+		FailedTest:    run.FailedTest,
+		CompileOutput: run.CompileOutput,
+		Stdout:        run.Stdout,
+		Stderr:        run.Stderr,
+		RuntimeMs:     int(run.Time * 1000),
+		MemoryKB:      run.Memory,
+		CreatedAt:     time.Now(),
+	}
+
+	if err := app.store.Submissions.Create(context.Background(), sub); err != nil {
+		log.Println("Error storing submission:", err)
+	}
+
+	return sub
+}
@@ -2,90 +2,138 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
 	"net/http"
 	"strconv"
+	"time"
 	"ws_practice_1/internal/store"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
+var errSessionRevoked = errors.New("session has been revoked or has expired")
+
 func (app *application) AuthTokenMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		cookie, err := r.Cookie("jwt")
+		cookie, err := r.Cookie(accessTokenCookie)
 		if err != nil {
 			app.unauthorizedErrorResponse(w, r, fmt.Errorf("authorization cookie is missing"))
 			return
 		}
 
-		token := cookie.Value
-
-		jwtToken, err := app.authenticator.ValidateToken(token)
+		user, session, err := app.authenticate(r.Context(), cookie.Value)
 		if err != nil {
 			app.unauthorizedErrorResponse(w, r, err)
 			return
 		}
 
-		claims, _ := jwtToken.Claims.(jwt.MapClaims)
+		ctx := context.WithValue(r.Context(), userCtx, user)
+		ctx = context.WithValue(ctx, sessionCtx, session)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
 
-		userID, err := strconv.ParseInt(fmt.Sprintf("%.f", claims["sub"]), 10, 64)
-		if err != nil {
-			app.unauthorizedErrorResponse(w, r, err)
-			return
-		}
+// RoleMiddleware lets a request through only if the authenticated user has
+// at least the given role. It must run after AuthTokenMiddleware. The role
+// is read from the freshly loaded user rather than the token's claims, so a
+// demotion takes effect straight away.
+func (app *application) RoleMiddleware(role string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user, ok := r.Context().Value(userCtx).(*store.User)
+			if !ok || user == nil {
+				app.unauthorizedErrorResponse(w, r, fmt.Errorf("no user in context"))
+				return
+			}
+
+			if !user.HasRole(role) {
+				app.forbiddenResponse(w, r, fmt.Errorf("user %d has role %q, needs %q", user.ID, user.Role, role))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
 
-		ctx := r.Context()
+// WSAuthMiddleware authenticates a WebSocket upgrade with a ticket from
+// createWSTicketHandler, or with the access token cookie when the request
+// comes from an allowed origin. Tokens are never taken from the URL since
+// it ends up in access logs.
+func (app *application) WSAuthMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var (
+			user    *store.User
+			session *store.Session
+			err     error
+		)
+
+		if id := r.URL.Query().Get("ticket"); id != "" {
+			ticket, ok := app.wsTickets.Redeem(id)
+			if !ok {
+				app.unauthorizedErrorResponse(w, r, fmt.Errorf("ticket is invalid or expired"))
+				return
+			}
+			user, session, err = app.loadSession(r.Context(), ticket.UserID, ticket.SessionID)
+		} else if cookie, cookieErr := r.Cookie(accessTokenCookie); cookieErr == nil && app.originAllowed(r.Header.Get("Origin")) {
+			user, session, err = app.authenticate(r.Context(), cookie.Value)
+		} else {
+			err = fmt.Errorf("a ticket or an authorization cookie from an allowed origin is required")
+		}
 
-		user, err := app.store.Users.GetByID(ctx, userID)
 		if err != nil {
 			app.unauthorizedErrorResponse(w, r, err)
 			return
 		}
 
-		ctx = context.WithValue(ctx, userCtx, user)
-		fmt.Println(user)
+		ctx := context.WithValue(r.Context(), userCtx, user)
+		ctx = context.WithValue(ctx, sessionCtx, session)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
-func (app *application) ParamAuthTokenMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		cookie := r.URL.Query().Get("token")
+// authenticate validates an access token and loads its user and session.
+// Tokens whose session was revoked or has expired are rejected even if the
+// token itself has not expired yet.
+func (app *application) authenticate(ctx context.Context, token string) (*store.User, *store.Session, error) {
+	jwtToken, err := app.authenticator.ValidateToken(token)
+	if err != nil {
+		return nil, nil, err
+	}
 
-		if cookie == "" {
-			app.unauthorizedErrorResponse(w, r, fmt.Errorf("authorization cookie is missing"))
-			return
-		}
+	claims, _ := jwtToken.Claims.(jwt.MapClaims)
 
-		token := cookie
+	userID, err := strconv.ParseInt(fmt.Sprintf("%.f", claims["sub"]), 10, 64)
+	if err != nil {
+		return nil, nil, err
+	}
 
-		jwtToken, err := app.authenticator.ValidateToken(token)
-		if err != nil {
-			app.unauthorizedErrorResponse(w, r, err)
-			return
-		}
+	sessionID, err := strconv.ParseInt(fmt.Sprintf("%.f", claims["sid"]), 10, 64)
+	if err != nil {
+		return nil, nil, fmt.Errorf("token has no session")
+	}
 
-		claims, _ := jwtToken.Claims.(jwt.MapClaims)
+	return app.loadSession(ctx, userID, sessionID)
+}
 
-		userID, err := strconv.ParseInt(fmt.Sprintf("%.f", claims["sub"]), 10, 64)
-		if err != nil {
-			app.unauthorizedErrorResponse(w, r, err)
-			return
-		}
+// loadSession loads the user and checks their session is still active.
+func (app *application) loadSession(ctx context.Context, userID, sessionID int64) (*store.User, *store.Session, error) {
+	session, err := app.store.Sessions.GetByID(ctx, sessionID)
+	if err != nil {
+		return nil, nil, err
+	}
 
-		ctx := r.Context()
+	if session.UserID != userID || !session.Active(time.Now()) {
+		return nil, nil, errSessionRevoked
+	}
 
-		user, err := app.store.Users.GetByID(ctx, userID)
-		if err != nil {
-			app.unauthorizedErrorResponse(w, r, err)
-			return
-		}
+	user, err := app.store.Users.GetByID(ctx, userID)
+	if err != nil {
+		return nil, nil, err
+	}
 
-		ctx = context.WithValue(ctx, userCtx, user)
-		log.Println("CTX:", user.ID)
-		next.ServeHTTP(w, r.WithContext(ctx))
-	})
+	return user, session, nil
 }
 
 func (app *application) verifyTokenHandler(w http.ResponseWriter, r *http.Request) {
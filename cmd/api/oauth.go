@@ -0,0 +1,290 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"strings"
+	"time"
+	"ws_practice_1/internal/oauth"
+	"ws_practice_1/internal/store"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	// oauthStateCookie ties the callback to the browser that started the
+	// flow, so nobody can finish a sign-in in someone else's browser.
+	oauthStateCookie = "oauth_state"
+	oauthCookiePath  = refreshCookiePath + "/oauth"
+
+	maxOAuthUsernameLen    = 50
+	oauthUsernameAttempts  = 5
+	oauthHTTPClientTimeout = 10 * time.Second
+)
+
+var (
+	errIdentityLinked = errors.New("this provider account is linked to another user")
+	errProviderLinked = errors.New("another account at this provider is already linked")
+	errEmailInUse     = errors.New("an account with this email already exists, sign in to it first and link the provider")
+	errNoEmail        = errors.New("the provider did not share an email address")
+)
+
+func newOAuthProviders(cfg oauthConfig) map[string]*oauth.Provider {
+	client := &http.Client{Timeout: oauthHTTPClientTimeout}
+	providers := make(map[string]*oauth.Provider)
+
+	for name, providerCfg := range cfg.providers {
+		if providerCfg.ClientID == "" {
+			continue
+		}
+		providerCfg.RedirectURL = strings.TrimSuffix(cfg.callbackURL, "/") + "/" + name + "/callback"
+
+		switch name {
+		case "github":
+			providers[name] = oauth.NewGitHub(providerCfg, client)
+		default:
+			providers[name] = oauth.NewOIDC(providerCfg, client)
+		}
+		log.Printf("OAuth sign-in enabled for %s\n", name)
+	}
+
+	return providers
+}
+
+// oauthLoginHandler sends the browser to the provider to sign in or sign
+// up.
+func (app *application) oauthLoginHandler(w http.ResponseWriter, r *http.Request) {
+	app.startOAuth(w, r, 0)
+}
+
+// oauthLinkHandler sends the signed-in user to the provider so the account
+// there can be linked to theirs.
+func (app *application) oauthLinkHandler(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value(userCtx).(*store.User)
+	app.startOAuth(w, r, user.ID)
+}
+
+func (app *application) startOAuth(w http.ResponseWriter, r *http.Request, userID int64) {
+	name := chi.URLParam(r, "provider")
+	provider, ok := app.oauth[name]
+	if !ok {
+		app.notFoundResponse(w, r, fmt.Errorf("unknown oauth provider %q", name))
+		return
+	}
+
+	state, flow, err := app.oauthFlows.Start(name, userID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	authURL, err := provider.AuthCodeURL(r.Context(), state, flow.Verifier, flow.Nonce)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	setAuthCookie(w, oauthStateCookie, state, oauthCookiePath, flow.ExpiresAt)
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// oauthCallbackHandler finishes a flow when the provider redirects back.
+// Sign-ins get the same cookies as createTokenHandler; either way the
+// browser ends up back on the frontend.
+func (app *application) oauthCallbackHandler(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "provider")
+	provider, ok := app.oauth[name]
+	if !ok {
+		app.notFoundResponse(w, r, fmt.Errorf("unknown oauth provider %q", name))
+		return
+	}
+
+	query := r.URL.Query()
+	state := query.Get("state")
+
+	cookie, err := r.Cookie(oauthStateCookie)
+	clearOAuthStateCookie(w)
+	if err != nil || state == "" || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(state)) != 1 {
+		app.unauthorizedErrorResponse(w, r, fmt.Errorf("oauth state does not match"))
+		return
+	}
+
+	flow, ok := app.oauthFlows.Finish(state)
+	if !ok || flow.Provider != name {
+		app.unauthorizedErrorResponse(w, r, fmt.Errorf("oauth state is invalid or expired"))
+		return
+	}
+
+	if reason := query.Get("error"); reason != "" {
+		app.unauthorizedErrorResponse(w, r, fmt.Errorf("%s refused the sign-in: %s", name, reason))
+		return
+	}
+
+	ctx := r.Context()
+
+	profile, err := provider.Exchange(ctx, query.Get("code"), flow.Verifier, flow.Nonce)
+	if err != nil {
+		switch {
+		case errors.Is(err, oauth.ErrDenied):
+			app.unauthorizedErrorResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	user, err := app.oauthUser(ctx, name, profile, flow.UserID)
+	if err != nil {
+		switch err {
+		case errIdentityLinked, errProviderLinked, errEmailInUse, store.ErrConflict, store.ErrDuplicateEmail:
+			app.conflictResponse(w, r, err)
+		case errNoEmail:
+			app.badRequestResponse(w, r, err)
+		case store.ErrNotFound:
+			app.unauthorizedErrorResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	// Linking happens in an existing session, so there is nothing to sign
+	// in to.
+	if flow.UserID == 0 {
+		if _, err := app.startSession(w, r, user); err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+
+	http.Redirect(w, r, app.config.appURL, http.StatusFound)
+}
+
+// oauthUser finds the user a provider profile belongs to, linking or
+// creating one as needed. linkUserID is the user linking the provider, or
+// 0 for a sign-in.
+func (app *application) oauthUser(ctx context.Context, provider string, profile *oauth.Profile, linkUserID int64) (*store.User, error) {
+	identity, err := app.store.UserIdentities.GetBySubject(ctx, provider, profile.Subject)
+	switch err {
+	case nil:
+		if linkUserID != 0 && identity.UserID != linkUserID {
+			return nil, errIdentityLinked
+		}
+		return app.store.Users.GetByID(ctx, identity.UserID)
+	case store.ErrNotFound:
+	default:
+		return nil, err
+	}
+
+	identity = &store.UserIdentity{
+		Provider: provider,
+		Subject:  profile.Subject,
+		Email:    profile.Email,
+	}
+
+	if linkUserID != 0 {
+		identity.UserID = linkUserID
+		if err := app.store.UserIdentities.Create(ctx, identity); err != nil {
+			if err == store.ErrConflict {
+				return nil, errProviderLinked
+			}
+			return nil, err
+		}
+		return app.store.Users.GetByID(ctx, linkUserID)
+	}
+
+	if profile.Email == "" {
+		return nil, errNoEmail
+	}
+
+	user, err := app.store.Users.GetByEmail(ctx, profile.Email)
+	switch err {
+	case nil:
+		// Both sides must have proven they own the address. Otherwise
+		// someone could register a victim's email first and take over
+		// the account once the victim signs in with the provider.
+		if !profile.EmailVerified || !user.EmailVerified() {
+			return nil, errEmailInUse
+		}
+
+		identity.UserID = user.ID
+		if err := app.store.UserIdentities.Create(ctx, identity); err != nil {
+			return nil, err
+		}
+		return user, nil
+	case store.ErrNotFound:
+		return app.createOAuthUser(ctx, profile, identity)
+	default:
+		return nil, err
+	}
+}
+
+// createOAuthUser signs up a new user from a provider profile. They have no
+// password until they set one through a password reset.
+func (app *application) createOAuthUser(ctx context.Context, profile *oauth.Profile, identity *store.UserIdentity) (*store.User, error) {
+	base := oauthUsername(profile)
+	user := &store.User{
+		Email:    profile.Email,
+		Username: base,
+		Password: []byte{},
+	}
+	if profile.EmailVerified {
+		now := time.Now()
+		user.EmailVerifiedAt = &now
+	}
+
+	// The provider's username may be taken here, so fall back to adding
+	// a number to it.
+	for attempt := 1; ; attempt++ {
+		err := app.store.Users.CreateWithIdentity(ctx, user, identity)
+		if err == nil {
+			break
+		}
+		if err != store.ErrDuplicateUsername || attempt == oauthUsernameAttempts {
+			return nil, err
+		}
+		user.Username = fmt.Sprintf("%s-%04d", base, rand.IntN(10000))
+	}
+
+	if !user.EmailVerified() {
+		if err := app.sendVerificationEmail(ctx, user); err != nil {
+			log.Printf("Error sending verification email to user %d: %v\n", user.ID, err)
+		}
+	}
+
+	return user, nil
+}
+
+func oauthUsername(profile *oauth.Profile) string {
+	username := strings.Join(strings.Fields(profile.Username), "-")
+	if username == "" {
+		username, _, _ = strings.Cut(profile.Email, "@")
+	}
+	if username == "" {
+		username = "player"
+	}
+
+	if len(username) > maxOAuthUsernameLen {
+		username = strings.ToValidUTF8(username[:maxOAuthUsernameLen], "")
+	}
+
+	return username
+}
+
+func clearOAuthStateCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     oauthStateCookie,
+		Value:    "",
+		Path:     oauthCookiePath,
+		Expires:  time.Unix(0, 0),
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteNoneMode,
+	})
+}
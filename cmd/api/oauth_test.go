@@ -0,0 +1,374 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"ws_practice_1/internal/auth"
+	"ws_practice_1/internal/oauth"
+	"ws_practice_1/internal/oauth/oauthtest"
+	"ws_practice_1/internal/store"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const testAppURL = "http://localhost:5173"
+
+var errNotFaked = errors.New("not part of the fake")
+
+type fakeUsers struct {
+	users  map[int64]*store.User
+	nextID int64
+	ids    *fakeIdentities
+}
+
+func (f *fakeUsers) GetByID(_ context.Context, id int64) (*store.User, error) {
+	user, ok := f.users[id]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	copied := *user
+	return &copied, nil
+}
+
+func (f *fakeUsers) GetByEmail(_ context.Context, email string) (*store.User, error) {
+	for _, user := range f.users {
+		if user.Email == email {
+			copied := *user
+			return &copied, nil
+		}
+	}
+	return nil, store.ErrNotFound
+}
+
+func (f *fakeUsers) Create(_ context.Context, user *store.User) error {
+	for _, other := range f.users {
+		switch {
+		case other.Email == user.Email:
+			return store.ErrDuplicateEmail
+		case other.Username == user.Username:
+			return store.ErrDuplicateUsername
+		}
+	}
+
+	f.nextID++
+	user.ID = f.nextID
+	if user.Role == "" {
+		user.Role = store.RoleUser
+	}
+	copied := *user
+	f.users[user.ID] = &copied
+	return nil
+}
+
+func (f *fakeUsers) CreateWithIdentity(ctx context.Context, user *store.User, identity *store.UserIdentity) error {
+	if err := f.Create(ctx, user); err != nil {
+		return err
+	}
+	identity.UserID = user.ID
+	return f.ids.Create(ctx, identity)
+}
+
+func (f *fakeUsers) Update(context.Context, *store.User) error {
+	return errNotFaked
+}
+
+func (f *fakeUsers) Delete(context.Context, int64) error {
+	return errNotFaked
+}
+
+func (f *fakeUsers) GetByUsername(context.Context, string) (*store.User, error) {
+	return nil, errNotFaked
+}
+
+func (f *fakeUsers) SetRole(context.Context, int64, string) error {
+	return errNotFaked
+}
+
+func (f *fakeUsers) VerifyEmail(context.Context, int64) error {
+	return errNotFaked
+}
+
+type fakeIdentities struct {
+	identities []store.UserIdentity
+}
+
+func (f *fakeIdentities) GetBySubject(_ context.Context, provider, subject string) (*store.UserIdentity, error) {
+	for _, identity := range f.identities {
+		if identity.Provider == provider && identity.Subject == subject {
+			return &identity, nil
+		}
+	}
+	return nil, store.ErrNotFound
+}
+
+func (f *fakeIdentities) Create(_ context.Context, identity *store.UserIdentity) error {
+	for _, other := range f.identities {
+		if other.Provider == identity.Provider && (other.Subject == identity.Subject || other.UserID == identity.UserID) {
+			return store.ErrConflict
+		}
+	}
+
+	identity.ID = int64(len(f.identities) + 1)
+	f.identities = append(f.identities, *identity)
+	return nil
+}
+
+type fakeSessions struct {
+	sessions []store.Session
+}
+
+func (f *fakeSessions) Create(_ context.Context, session *store.Session, _ []byte) error {
+	session.ID = int64(len(f.sessions) + 1)
+	f.sessions = append(f.sessions, *session)
+	return nil
+}
+
+func (f *fakeSessions) GetByID(context.Context, int64) (*store.Session, error) {
+	return nil, errNotFaked
+}
+
+func (f *fakeSessions) Rotate(context.Context, []byte, []byte, time.Time) (*store.Session, error) {
+	return nil, errNotFaked
+}
+
+func (f *fakeSessions) Revoke(context.Context, int64, int64) error {
+	return errNotFaked
+}
+
+func (f *fakeSessions) RevokeAllForUser(context.Context, int64) (int64, error) {
+	return 0, errNotFaked
+}
+
+func (f *fakeSessions) RevokeOthersForUser(context.Context, int64, int64) (int64, error) {
+	return 0, errNotFaked
+}
+
+type oauthTest struct {
+	app        *application
+	idp        *oauthtest.Server
+	users      *fakeUsers
+	identities *fakeIdentities
+	sessions   *fakeSessions
+}
+
+func newOAuthTest(t *testing.T, profile oauthtest.User) *oauthTest {
+	t.Helper()
+
+	idp := oauthtest.NewServer(t, profile)
+	identities := &fakeIdentities{}
+	users := &fakeUsers{users: make(map[int64]*store.User), ids: identities}
+	sessions := &fakeSessions{}
+
+	cfg := config{
+		appURL: testAppURL,
+		auth: authConfig{
+			token: tokenConfig{secret: "test", exp: time.Hour, refreshExp: 24 * time.Hour, iss: "test"},
+		},
+		oauth: oauthConfig{
+			callbackURL: "http://localhost:8080/api/v1/authentication/oauth",
+			providers: map[string]oauth.Config{
+				"oidc": {
+					ClientID:     oauthtest.ClientID,
+					ClientSecret: oauthtest.ClientSecret,
+					Issuer:       idp.Issuer(),
+				},
+			},
+		},
+	}
+
+	app := &application{
+		config: cfg,
+		store: store.Storage{
+			Users:          users,
+			UserIdentities: identities,
+			Sessions:       sessions,
+		},
+		authenticator: auth.NewJWTAuthenticator(cfg.auth.token.secret, cfg.auth.token.iss, cfg.auth.token.iss),
+		oauth:         newOAuthProviders(cfg.oauth),
+		oauthFlows:    oauth.NewFlows(time.Minute),
+	}
+
+	return &oauthTest{app: app, idp: idp, users: users, identities: identities, sessions: sessions}
+}
+
+func (o *oauthTest) addUser(t *testing.T, email string, verified bool) *store.User {
+	t.Helper()
+
+	user := &store.User{Email: email, Username: email}
+	if verified {
+		now := time.Now()
+		user.EmailVerifiedAt = &now
+	}
+	if err := o.users.Create(context.Background(), user); err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+// signIn goes through the whole flow in a browser, starting as linkUser or
+// signed out when it is nil, and returns the callback's response.
+func (o *oauthTest) signIn(t *testing.T, linkUser *store.User) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req := providerRequest(httptest.NewRequest(http.MethodGet, "/api/v1/authentication/oauth/oidc/", nil))
+	rec := httptest.NewRecorder()
+	if linkUser != nil {
+		req = req.WithContext(context.WithValue(req.Context(), userCtx, linkUser))
+		o.app.oauthLinkHandler(rec, req)
+	} else {
+		o.app.oauthLoginHandler(rec, req)
+	}
+	if rec.Code != http.StatusFound {
+		t.Fatalf("starting the flow returned %d: %s", rec.Code, rec.Body)
+	}
+
+	callback, err := o.idp.Authorize(rec.Header().Get("Location"))
+	if err != nil {
+		t.Fatalf("authorizing: %v", err)
+	}
+
+	req = providerRequest(httptest.NewRequest(http.MethodGet, callback, nil))
+	for _, cookie := range rec.Result().Cookies() {
+		req.AddCookie(cookie)
+	}
+
+	rec = httptest.NewRecorder()
+	o.app.oauthCallbackHandler(rec, req)
+	return rec
+}
+
+func providerRequest(req *http.Request) *http.Request {
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("provider", "oidc")
+	return req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+}
+
+func expectSignedIn(t *testing.T, rec *httptest.ResponseRecorder) {
+	t.Helper()
+
+	if rec.Code != http.StatusFound || rec.Header().Get("Location") != testAppURL {
+		t.Fatalf("callback returned %d to %q, want a redirect to the app: %s", rec.Code, rec.Header().Get("Location"), rec.Body)
+	}
+	for _, cookie := range rec.Result().Cookies() {
+		if cookie.Name == accessTokenCookie && cookie.Value != "" {
+			return
+		}
+	}
+	t.Fatal("callback set no access token cookie")
+}
+
+func TestOAuthSignUpAndSignIn(t *testing.T) {
+	o := newOAuthTest(t, oauthtest.User{Subject: "sub-1", Email: "ada@example.com", EmailVerified: true, Username: "Ada Lovelace"})
+
+	expectSignedIn(t, o.signIn(t, nil))
+
+	user, err := o.users.GetByEmail(context.Background(), "ada@example.com")
+	if err != nil {
+		t.Fatalf("no user was created: %v", err)
+	}
+	if user.Username != "Ada-Lovelace" || !user.EmailVerified() {
+		t.Errorf("created user = %+v, want username Ada-Lovelace with a verified email", user)
+	}
+
+	expectSignedIn(t, o.signIn(t, nil))
+
+	if len(o.users.users) != 1 || len(o.identities.identities) != 1 {
+		t.Errorf("have %d users and %d identities after signing in again, want 1 of each", len(o.users.users), len(o.identities.identities))
+	}
+	for _, session := range o.sessions.sessions {
+		if session.UserID != user.ID {
+			t.Errorf("session for user %d, want %d", session.UserID, user.ID)
+		}
+	}
+}
+
+func TestOAuthLinksVerifiedEmail(t *testing.T) {
+	o := newOAuthTest(t, oauthtest.User{Subject: "sub-1", Email: "ada@example.com", EmailVerified: true})
+	existing := o.addUser(t, "ada@example.com", true)
+
+	expectSignedIn(t, o.signIn(t, nil))
+
+	if len(o.identities.identities) != 1 || o.identities.identities[0].UserID != existing.ID {
+		t.Fatalf("identities = %+v, want one for user %d", o.identities.identities, existing.ID)
+	}
+	if len(o.users.users) != 1 {
+		t.Errorf("have %d users, want the existing one only", len(o.users.users))
+	}
+}
+
+func TestOAuthRefusesUnprovenEmail(t *testing.T) {
+	tests := []struct {
+		name             string
+		providerVerified bool
+		userVerified     bool
+	}{
+		{"unverified here", true, false},
+		{"unverified at provider", false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := newOAuthTest(t, oauthtest.User{Subject: "sub-1", Email: "ada@example.com", EmailVerified: tt.providerVerified})
+			o.addUser(t, "ada@example.com", tt.userVerified)
+
+			rec := o.signIn(t, nil)
+			if rec.Code != http.StatusConflict {
+				t.Fatalf("callback returned %d, want %d", rec.Code, http.StatusConflict)
+			}
+			if len(o.identities.identities) != 0 || len(o.sessions.sessions) != 0 {
+				t.Errorf("linked %d identities and started %d sessions, want none", len(o.identities.identities), len(o.sessions.sessions))
+			}
+		})
+	}
+}
+
+func TestOAuthLinkToSignedInUser(t *testing.T) {
+	o := newOAuthTest(t, oauthtest.User{Subject: "sub-1", Email: "someone-else@example.com", EmailVerified: true})
+	user := o.addUser(t, "ada@example.com", false)
+
+	rec := o.signIn(t, user)
+	if rec.Code != http.StatusFound {
+		t.Fatalf("callback returned %d: %s", rec.Code, rec.Body)
+	}
+
+	if len(o.identities.identities) != 1 || o.identities.identities[0].UserID != user.ID {
+		t.Fatalf("identities = %+v, want one for user %d", o.identities.identities, user.ID)
+	}
+	if len(o.sessions.sessions) != 0 {
+		t.Errorf("linking started %d sessions, want none", len(o.sessions.sessions))
+	}
+}
+
+func TestOAuthLinkTakenIdentity(t *testing.T) {
+	o := newOAuthTest(t, oauthtest.User{Subject: "sub-1", Email: "ada@example.com", EmailVerified: true})
+	expectSignedIn(t, o.signIn(t, nil))
+
+	other := o.addUser(t, "grace@example.com", true)
+	if rec := o.signIn(t, other); rec.Code != http.StatusConflict {
+		t.Fatalf("linking a taken identity returned %d, want %d", rec.Code, http.StatusConflict)
+	}
+
+	// A second account at the same provider cannot be linked either.
+	o.idp.SetUser(oauthtest.User{Subject: "sub-2", Email: "ada@example.com", EmailVerified: true})
+	owner, _ := o.users.GetByEmail(context.Background(), "ada@example.com")
+	if rec := o.signIn(t, owner); rec.Code != http.StatusConflict {
+		t.Fatalf("linking a second identity returned %d, want %d", rec.Code, http.StatusConflict)
+	}
+}
+
+func TestOAuthCallbackNeedsState(t *testing.T) {
+	o := newOAuthTest(t, oauthtest.User{Subject: "sub-1", Email: "ada@example.com", EmailVerified: true})
+
+	req := providerRequest(httptest.NewRequest(http.MethodGet, "/api/v1/authentication/oauth/oidc/callback?code=abc&state=forged", nil))
+	req.AddCookie(&http.Cookie{Name: oauthStateCookie, Value: "forged"})
+	rec := httptest.NewRecorder()
+	o.app.oauthCallbackHandler(rec, req)
+
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("callback with an unknown state returned %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+}
@@ -0,0 +1,311 @@
+package main
+
+import (
+	"encoding/json"
+	"time"
+	"ws_practice_1/internal/store"
+)
+
+// WebSocket protocol, version 1.
+//
+// Connect to /api/v1/ws?ticket=... with a ticket from POST /api/v1/ws/ticket.
+// Browsers on an allowed origin may instead rely on the jwt cookie.
+//
+// Every frame in either direction is a JSON envelope:
+//
+//	{"v": 1, "id": "42", "type": "submit", "data": {...}}
+//
+// Clients send commands and should give each one a unique id. The server
+// replies to every command with an "ack" or an "error" whose reply_to is
+// that id. Work that takes time, such as judging, is acked as soon as it is
+// accepted and its result follows later as an event with the same reply_to.
+//
+// Opening the socket only starts a session. Players enter matchmaking with
+// join_queue, optionally choosing a mode ("ranked", the default, or
+// "casual", which leaves ratings alone), a difficulty ("easy", "medium" or
+// "hard") and a question tag such as "greedy"; empty means any. When a
+// match ends the session stays open and the player can join_queue again.
+//
+// Server events carry their own id, which increases by one for every
+// frame sent on a connection, so clients can spot gaps. A frame that is
+// not valid JSON, has the wrong version, or has an unknown type is answered
+// with an "error" event instead of being dropped.
+//
+// Client commands:
+//
+//	join_queue   {"mode", "difficulty", "tag"}       enter matchmaking
+//	leave_queue  {}                                  leave matchmaking
+//	submit       {"language_id", "source_code"}      judge an answer
+//	run          {"language_id", "source_code", "stdin"}
+//	                                                 run against the samples, or stdin if given
+//	run_sample   {"language_id", "source_code"}      deprecated, run against the samples only
+//	forfeit      {}                                  concede the current match
+//	typing       {}                                  the player is editing code
+//	ping         {}                                  answered with "pong"
+//
+// run_sample is kept for clients written before run existed and is
+// answered with a "sample_result" event rather than "run_result".
+//
+// typing is fire-and-forget: it is never acked, and clients should send it
+// every few seconds while the player types. The opponent sees "typing"
+// once, then "idle" when the updates stop.
+//
+// Server events:
+//
+//	ack, pong, error
+//	queue_status, room_waiting, room_cancelled
+//	matched, question, match_state, time_remaining
+//	judging, verdict, run_result, sample_result
+//	opponent_progress, opponent_disconnected, opponent_reconnected
+//	match_end
+const protocolVersion = 1
+
+const (
+	cmdJoinQueue  = "join_queue"
+	cmdLeaveQueue = "leave_queue"
+	cmdSubmit     = "submit"
+	cmdRun        = "run"
+	cmdRunSample  = "run_sample"
+	cmdForfeit    = "forfeit"
+	cmdTyping     = "typing"
+	cmdPing       = "ping"
+)
+
+const (
+	evtAck                  = "ack"
+	evtPong                 = "pong"
+	evtError                = "error"
+	evtQueueStatus          = "queue_status"
+	evtRoomWaiting          = "room_waiting"
+	evtRoomCancelled        = "room_cancelled"
+	evtMatched              = "matched"
+	evtQuestion             = "question"
+	evtMatchState           = "match_state"
+	evtTimeRemaining        = "time_remaining"
+	evtJudging              = "judging"
+	evtVerdict              = "verdict"
+	evtRunResult            = "run_result"
+	evtSampleResult         = "sample_result"
+	evtOpponentProgress     = "opponent_progress"
+	evtOpponentDisconnected = "opponent_disconnected"
+	evtOpponentReconnected  = "opponent_reconnected"
+	evtMatchEnd             = "match_end"
+)
+
+// Error codes sent in the "error" event.
+const (
+	errCodeBadMessage         = "bad_message"
+	errCodeUnsupportedVersion = "unsupported_version"
+	errCodeUnknownType        = "unknown_type"
+	errCodeNotInMatch         = "not_in_match"
+	errCodeInMatch            = "in_match"
+	errCodeInRoom             = "in_room"
+	errCodeNotQueued          = "not_queued"
+	errCodeTooManySubmissions = "too_many_submissions"
+	errCodeJudgeBusy          = "judge_busy"
+	errCodeJudgeFailed        = "judge_failed"
+	errCodeRoomClosed         = "room_closed"
+	errCodeOpponentLeft       = "opponent_left"
+	errCodeNoQuestion         = "no_question"
+	errCodeEmailUnverified    = "email_unverified"
+	errCodeInternal           = "internal_error"
+)
+
+// command is a frame received from the client.
+type command struct {
+	Version int             `json:"v"`
+	ID      string          `json:"id"`
+	Type    string          `json:"type"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// event is a frame sent to the client. client.send fills in the version
+// and id.
+type event struct {
+	Version int    `json:"v"`
+	ID      string `json:"id"`
+	ReplyTo string `json:"reply_to,omitempty"`
+	Type    string `json:"type"`
+	Data    any    `json:"data,omitempty"`
+}
+
+func newEvent(typ string, data any) event {
+	return event{Type: typ, Data: data}
+}
+
+func replyEvent(replyTo, typ string, data any) event {
+	return event{ReplyTo: replyTo, Type: typ, Data: data}
+}
+
+// wsError is a protocol error reported to the client in an "error" event.
+type wsError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *wsError) Error() string {
+	return e.Code + ": " + e.Message
+}
+
+func newWSError(code, message string) *wsError {
+	return &wsError{Code: code, Message: message}
+}
+
+const (
+	modeRanked = "ranked"
+	modeCasual = "casual"
+)
+
+type joinQueueCommand struct {
+	Mode       string `json:"mode" validate:"omitempty,oneof=ranked casual"`
+	Difficulty string `json:"difficulty" validate:"omitempty,oneof=easy medium hard"`
+	Tag        string `json:"tag" validate:"omitempty,max=50"`
+}
+
+type submitCommand struct {
+	LanguageID int    `json:"language_id"`
+	SourceCode string `json:"source_code"`
+}
+
+type runCommand struct {
+	LanguageID int     `json:"language_id"`
+	SourceCode string  `json:"source_code"`
+	Stdin      *string `json:"stdin"`
+}
+
+type queueStatus struct {
+	Position             int     `json:"position"`
+	QueueSize            int     `json:"queue_size"`
+	RatingWindow         int     `json:"rating_window"`
+	EstimatedWaitSeconds float64 `json:"estimated_wait_seconds"`
+}
+
+type Opponent struct {
+	Username        string `json:"username"`
+	Points          int    `json:"points"`
+	RatingDeviation int    `json:"rating_deviation"`
+}
+
+type matchedEvent struct {
+	MatchID     int64     `json:"match_id"`
+	Opponent    *Opponent `json:"opponent"`
+	Mode        string    `json:"mode"`
+	Difficulty  string    `json:"difficulty,omitempty"`
+	Tag         string    `json:"tag,omitempty"`
+	SecondsLeft int       `json:"seconds_left"`
+	RoomCode    string    `json:"room_code,omitempty"`
+}
+
+type questionEvent struct {
+	MatchID  int64             `json:"match_id"`
+	Question store.DSAQuestion `json:"question"`
+}
+
+// matchState is everything a reconnecting player needs to pick up where
+// they left off.
+type matchState struct {
+	MatchID     int64             `json:"match_id"`
+	Opponent    *Opponent         `json:"opponent"`
+	Question    store.DSAQuestion `json:"question"`
+	SecondsLeft int               `json:"seconds_left"`
+	Submissions []matchSubmission `json:"submissions"`
+}
+
+type timeRemaining struct {
+	SecondsLeft int `json:"seconds_left"`
+}
+
+type judgingStatus struct {
+	LanguageID int `json:"language_id"`
+	TotalTests int `json:"total_tests"`
+}
+
+// verdict is the judged result of a submission. FailedTest is the 1-based
+// index of the first failing test and is omitted when every test passed.
+type verdict struct {
+	SubmissionID  int64        `json:"submission_id"`
+	Verdict       string       `json:"verdict"`
+	Message       string       `json:"message"`
+	TestsPassed   int          `json:"tests_passed"`
+	TotalTests    int          `json:"total_tests"`
+	FailedTest    int          `json:"failed_test,omitempty"`
+	CompileOutput string       `json:"compile_output,omitempty"`
+	Time          float64      `json:"time"`
+	Memory        int          `json:"memory"`
+	Tests         []testReport `json:"tests"`
+}
+
+// runCase is the outcome of one run. Expected and Passed are only set when
+// running against a sample.
+type runCase struct {
+	Input         string  `json:"input"`
+	Expected      string  `json:"expected_output,omitempty"`
+	Passed        *bool   `json:"passed,omitempty"`
+	Status        string  `json:"status"`
+	Stdout        string  `json:"stdout"`
+	Stderr        string  `json:"stderr"`
+	CompileOutput string  `json:"compile_output,omitempty"`
+	Time          float64 `json:"time"`
+	Memory        int     `json:"memory"`
+}
+
+type runResult struct {
+	Cases []runCase `json:"cases"`
+}
+
+// sampleResult answers the deprecated run_sample command.
+type sampleResult struct {
+	Verdict     string `json:"verdict"`
+	TestsPassed int    `json:"tests_passed"`
+	TotalTests  int    `json:"total_tests"`
+	Stdout      string `json:"stdout"`
+	Stderr      string `json:"stderr"`
+}
+
+const (
+	progressSubmitted = "submitted"
+	progressJudged    = "judged"
+	progressTyping    = "typing"
+	progressIdle      = "idle"
+)
+
+// opponentProgress tells a player what their opponent is up to without
+// revealing any code or output. For "judged" TestsPassed is the result of
+// that submission; otherwise it is the opponent's best so far.
+type opponentProgress struct {
+	Status      string `json:"status"`
+	TestsPassed int    `json:"tests_passed"`
+	TotalTests  int    `json:"total_tests"`
+}
+
+type reconnectWindow struct {
+	SecondsLeft int `json:"seconds_left"`
+}
+
+type opponentReconnected struct {
+	Username string `json:"username"`
+}
+
+const (
+	resultWin  = "win"
+	resultLoss = "loss"
+	resultDraw = "draw"
+)
+
+const (
+	endReasonSolved     = "solved"
+	endReasonTimeout    = "timeout"
+	endReasonForfeit    = "forfeit"
+	endReasonDisconnect = "disconnect"
+)
+
+type matchEnd struct {
+	MatchID             int64        `json:"match_id"`
+	Result              string       `json:"result"`
+	Reason              string       `json:"reason"`
+	TestsPassed         int          `json:"tests_passed"`
+	OpponentTestsPassed int          `json:"opponent_tests_passed"`
+	TotalTests          int          `json:"total_tests"`
+	EndedAt             time.Time    `json:"ended_at"`
+	Rating              *ratingDelta `json:"rating,omitempty"`
+}
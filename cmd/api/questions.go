@@ -0,0 +1,335 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"ws_practice_1/internal/store"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	defaultQuestionsLimit = 50
+	maxQuestionsLimit     = 200
+)
+
+type QuestionPayload struct {
+	Title             string            `json:"title" validate:"required,max=200"`
+	Description       string            `json:"description" validate:"required"`
+	InputFormat       string            `json:"input_format" validate:"required"`
+	OutputFormat      string            `json:"output_format" validate:"required"`
+	ExampleInput      string            `json:"example_input"`
+	ExampleOutput     string            `json:"example_output"`
+	Difficulty        string            `json:"difficulty" validate:"required,oneof=easy medium hard"`
+	Rating            int               `json:"rating" validate:"omitempty,min=100,max=4000"`
+	Tags              []string          `json:"tags" validate:"max=10,dive,required,max=50"`
+	TimeLimitMs       int               `json:"time_limit_ms" validate:"omitempty,min=100,max=20000"`
+	MemoryLimitKB     int               `json:"memory_limit_kb" validate:"omitempty,min=16384,max=1048576"`
+	Checker           string            `json:"checker" validate:"omitempty,oneof=exact tokens tokens_ci float special"`
+	CheckerTolerance  float64           `json:"checker_tolerance" validate:"required_if=Checker float,gte=0"`
+	CheckerSource     string            `json:"checker_source" validate:"required_if=Checker special"`
+	CheckerLanguageID int               `json:"checker_language_id" validate:"required_if=Checker special"`
+	TestCases         []TestCasePayload `json:"test_cases" validate:"omitempty,max=100,dive"`
+}
+
+type TestCasePayload struct {
+	Input          string `json:"input"`
+	ExpectedOutput string `json:"expected_output"`
+	IsHidden       bool   `json:"is_hidden"`
+}
+
+type TestCasesPayload struct {
+	TestCases []TestCasePayload `json:"test_cases" validate:"required,min=1,max=100,dive"`
+}
+
+// questionDetail is the admin view of a question, including the checker
+// and test cases players never see.
+type questionDetail struct {
+	*store.DSAQuestion
+	CheckerTolerance  float64          `json:"checker_tolerance,omitempty"`
+	CheckerSource     string           `json:"checker_source,omitempty"`
+	CheckerLanguageID int              `json:"checker_language_id,omitempty"`
+	TestCases         []store.TestCase `json:"test_cases,omitempty"`
+}
+
+func newQuestionDetail(q *store.DSAQuestion, cases []store.TestCase) questionDetail {
+	return questionDetail{
+		DSAQuestion:       q,
+		CheckerTolerance:  q.Tolerance,
+		CheckerSource:     q.CheckerSource,
+		CheckerLanguageID: q.CheckerLangID,
+		TestCases:         cases,
+	}
+}
+
+func (p QuestionPayload) question() *store.DSAQuestion {
+	return &store.DSAQuestion{
+		Title:         p.Title,
+		Description:   p.Description,
+		InputFormat:   p.InputFormat,
+		OutputFormat:  p.OutputFormat,
+		ExampleInput:  p.ExampleInput,
+		ExampleOutput: p.ExampleOutput,
+		Difficulty:    p.Difficulty,
+		Rating:        p.Rating,
+		Tags:          p.Tags,
+		TimeLimitMs:   p.TimeLimitMs,
+		MemoryLimitKB: p.MemoryLimitKB,
+		Checker:       p.Checker,
+		Tolerance:     p.CheckerTolerance,
+		CheckerSource: p.CheckerSource,
+		CheckerLangID: p.CheckerLanguageID,
+		TestCases:     testCasesFromPayload(p.TestCases),
+	}
+}
+
+func testCasesFromPayload(payload []TestCasePayload) []store.TestCase {
+	var cases []store.TestCase
+	for _, tc := range payload {
+		cases = append(cases, store.TestCase{
+			Input:          tc.Input,
+			ExpectedOutput: tc.ExpectedOutput,
+			IsHidden:       tc.IsHidden,
+		})
+	}
+	return cases
+}
+
+// copyTestCases detaches test cases from their question so they can be
+// stored again under a new version.
+func copyTestCases(cases []store.TestCase) []store.TestCase {
+	copied := make([]store.TestCase, len(cases))
+	for i, tc := range cases {
+		copied[i] = store.TestCase{
+			Input:          tc.Input,
+			ExpectedOutput: tc.ExpectedOutput,
+			IsHidden:       tc.IsHidden,
+		}
+	}
+	return copied
+}
+
+func (app *application) listQuestionsHandler(w http.ResponseWriter, r *http.Request) {
+	limit, err := queryInt(r, "limit", defaultQuestionsLimit)
+	if err != nil || limit < 1 || limit > maxQuestionsLimit {
+		app.badRequestResponse(w, r, fmt.Errorf("limit must be between 1 and %d", maxQuestionsLimit))
+		return
+	}
+
+	offset, err := queryInt(r, "offset", 0)
+	if err != nil || offset < 0 {
+		app.badRequestResponse(w, r, fmt.Errorf("offset must be a non-negative integer"))
+		return
+	}
+
+	query := r.URL.Query()
+	questions, err := app.store.Questions.List(r.Context(), store.QuestionFilter{
+		Difficulty:     query.Get("difficulty"),
+		Tag:            query.Get("tag"),
+		IncludeRetired: query.Get("include_retired") == "true",
+		Limit:          limit,
+		Offset:         offset,
+	})
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, questions); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+func (app *application) createQuestionHandler(w http.ResponseWriter, r *http.Request) {
+	var payload QuestionPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if len(payload.TestCases) == 0 {
+		app.badRequestResponse(w, r, fmt.Errorf("at least one test case is required"))
+		return
+	}
+
+	q := payload.question()
+	if err := app.store.Questions.Create(r.Context(), q); err != nil {
+		switch err {
+		case store.ErrNoSample:
+			app.badRequestResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusCreated, newQuestionDetail(q, q.TestCases)); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+func (app *application) getQuestionHandler(w http.ResponseWriter, r *http.Request) {
+	q, ok := app.questionFromURL(w, r)
+	if !ok {
+		return
+	}
+
+	cases, err := app.store.Questions.GetTestCases(r.Context(), q.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, newQuestionDetail(q, cases)); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// updateQuestionHandler publishes a new version of the question. Test cases
+// are carried over unless the payload replaces them.
+func (app *application) updateQuestionHandler(w http.ResponseWriter, r *http.Request) {
+	current, ok := app.questionFromURL(w, r)
+	if !ok {
+		return
+	}
+
+	var payload QuestionPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	q := payload.question()
+	if len(q.TestCases) == 0 {
+		cases, err := app.store.Questions.GetTestCases(r.Context(), current.ID)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+		q.TestCases = copyTestCases(cases)
+	}
+
+	app.publishQuestionVersion(w, r, current, q)
+}
+
+func (app *application) listQuestionTestCasesHandler(w http.ResponseWriter, r *http.Request) {
+	q, ok := app.questionFromURL(w, r)
+	if !ok {
+		return
+	}
+
+	cases, err := app.store.Questions.GetTestCases(r.Context(), q.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, cases); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// replaceQuestionTestCasesHandler publishes a new version of the question
+// with a new set of test cases and everything else unchanged.
+func (app *application) replaceQuestionTestCasesHandler(w http.ResponseWriter, r *http.Request) {
+	current, ok := app.questionFromURL(w, r)
+	if !ok {
+		return
+	}
+
+	var payload TestCasesPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	q := *current
+	q.TestCases = testCasesFromPayload(payload.TestCases)
+
+	app.publishQuestionVersion(w, r, current, &q)
+}
+
+func (app *application) retireQuestionHandler(w http.ResponseWriter, r *http.Request) {
+	q, ok := app.questionFromURL(w, r)
+	if !ok {
+		return
+	}
+
+	if q.RetiredAt != nil {
+		app.conflictResponse(w, r, fmt.Errorf("question %d is already retired", q.ID))
+		return
+	}
+
+	if err := app.store.Questions.Retire(r.Context(), q.ID); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.conflictResponse(w, r, fmt.Errorf("question %d is already retired", q.ID))
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (app *application) publishQuestionVersion(w http.ResponseWriter, r *http.Request, current, next *store.DSAQuestion) {
+	if current.RetiredAt != nil {
+		app.conflictResponse(w, r, fmt.Errorf("question %d is retired, edit the latest version instead", current.ID))
+		return
+	}
+
+	next.ID = current.ID
+	if err := app.store.Questions.Update(r.Context(), next); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.conflictResponse(w, r, fmt.Errorf("question %d was changed concurrently", current.ID))
+		case store.ErrNoSample:
+			app.badRequestResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, newQuestionDetail(next, next.TestCases)); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+func (app *application) questionFromURL(w http.ResponseWriter, r *http.Request) (*store.DSAQuestion, bool) {
+	questionID, err := strconv.ParseInt(chi.URLParam(r, "questionID"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return nil, false
+	}
+
+	q, err := app.store.Questions.GetByID(r.Context(), questionID)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return nil, false
+	}
+
+	return q, true
+}
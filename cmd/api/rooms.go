@@ -0,0 +1,211 @@
+package main
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+	"ws_practice_1/internal/store"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// roomCodeAlphabet leaves out characters that are easy to misread when a
+// code is shared out loud or by hand.
+const roomCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+const roomCodeLength = 8
+
+var (
+	errRoomClosed   = errors.New("room is no longer open")
+	errRoomNotYours = errors.New("room belongs to other players")
+)
+
+type CreateRoomPayload struct {
+	Opponent         string `json:"opponent" validate:"omitempty,max=100"`
+	Difficulty       string `json:"difficulty" validate:"omitempty,oneof=easy medium hard"`
+	TimeLimitSeconds int    `json:"time_limit_seconds" validate:"omitempty,min=60,max=7200"`
+	// Rated rooms change both players' ratings. Rooms are casual unless
+	// the host asks for this.
+	Rated bool `json:"rated"`
+}
+
+func (app *application) createRoomHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(userCtx).(*store.User)
+	if !ok || user == nil {
+		app.unauthorizedErrorResponse(w, r, fmt.Errorf("no user in context"))
+		return
+	}
+
+	var payload CreateRoomPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	room := &store.Room{
+		HostID:           user.ID,
+		Difficulty:       payload.Difficulty,
+		TimeLimitSeconds: payload.TimeLimitSeconds,
+		Rated:            payload.Rated,
+		ExpiresAt:        time.Now().Add(app.config.rooms.ttl),
+	}
+
+	ctx := r.Context()
+
+	if payload.Opponent != "" {
+		opponent, err := app.store.Users.GetByUsername(ctx, payload.Opponent)
+		if err != nil {
+			switch err {
+			case store.ErrNotFound:
+				app.badRequestResponse(w, r, fmt.Errorf("user %q does not exist", payload.Opponent))
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+
+		if opponent.ID == user.ID {
+			app.badRequestResponse(w, r, fmt.Errorf("you cannot challenge yourself"))
+			return
+		}
+		room.GuestID = &opponent.ID
+	}
+
+	// Codes are random enough that a collision is rare; retry a few times
+	// rather than failing the request when one happens.
+	var err error
+	for range 3 {
+		room.Code, err = newRoomCode()
+		if err != nil {
+			break
+		}
+
+		err = app.store.Rooms.Create(ctx, room)
+		if err != store.ErrConflict {
+			break
+		}
+	}
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusCreated, room); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+func (app *application) listRoomsHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(userCtx).(*store.User)
+	if !ok || user == nil {
+		app.unauthorizedErrorResponse(w, r, fmt.Errorf("no user in context"))
+		return
+	}
+
+	rooms, err := app.store.Rooms.ListByUser(r.Context(), user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, rooms); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+func (app *application) cancelRoomHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(userCtx).(*store.User)
+	if !ok || user == nil {
+		app.unauthorizedErrorResponse(w, r, fmt.Errorf("no user in context"))
+		return
+	}
+
+	ctx := r.Context()
+
+	room, err := app.store.Rooms.GetByCode(ctx, chi.URLParam(r, "code"))
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if room.HostID != user.ID {
+		app.forbiddenResponse(w, r, fmt.Errorf("user %d cannot cancel room %s", user.ID, room.Code))
+		return
+	}
+
+	err = app.store.Rooms.UpdateStatus(ctx, room.ID, store.RoomStatusOpen, store.RoomStatusCancelled)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.conflictResponse(w, r, errRoomClosed)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.ws.closeRoom(room.Code)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// joinableRoom loads a room for the user opening a socket with ?room=CODE,
+// taking the guest seat if the host left it open to anyone.
+func (app *application) joinableRoom(r *http.Request, code string, user *store.User) (*store.Room, error) {
+	ctx := r.Context()
+
+	room, err := app.store.Rooms.GetByCode(ctx, code)
+	if err != nil {
+		return nil, err
+	}
+
+	if room.Status != store.RoomStatusOpen || time.Now().After(room.ExpiresAt) {
+		return nil, errRoomClosed
+	}
+
+	if room.HostID == user.ID {
+		return room, nil
+	}
+
+	if room.GuestID != nil {
+		if *room.GuestID != user.ID {
+			return nil, errRoomNotYours
+		}
+		return room, nil
+	}
+
+	if err := app.store.Rooms.ClaimGuest(ctx, room.ID, user.ID); err != nil {
+		if err == store.ErrConflict {
+			return nil, errRoomNotYours
+		}
+		return nil, err
+	}
+	room.GuestID = &user.ID
+
+	return room, nil
+}
+
+func newRoomCode() (string, error) {
+	buf := make([]byte, roomCodeLength)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+
+	for i, b := range buf {
+		buf[i] = roomCodeAlphabet[int(b)%len(roomCodeAlphabet)]
+	}
+
+	return string(buf), nil
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"ws_practice_1/internal/store"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	defaultSubmissionsLimit = 20
+	maxSubmissionsLimit     = 100
+)
+
+func (app *application) listSubmissionsHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(userCtx).(*store.User)
+	if !ok || user == nil {
+		app.unauthorizedErrorResponse(w, r, fmt.Errorf("no user in context"))
+		return
+	}
+
+	limit, err := queryInt(r, "limit", defaultSubmissionsLimit)
+	if err != nil || limit < 1 || limit > maxSubmissionsLimit {
+		app.badRequestResponse(w, r, fmt.Errorf("limit must be between 1 and %d", maxSubmissionsLimit))
+		return
+	}
+
+	offset, err := queryInt(r, "offset", 0)
+	if err != nil || offset < 0 {
+		app.badRequestResponse(w, r, fmt.Errorf("offset must be a non-negative integer"))
+		return
+	}
+
+	subs, err := app.store.Submissions.ListByUser(r.Context(), user.ID, limit, offset)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, subs); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+func (app *application) getSubmissionHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(userCtx).(*store.User)
+	if !ok || user == nil {
+		app.unauthorizedErrorResponse(w, r, fmt.Errorf("no user in context"))
+		return
+	}
+
+	submissionID, err := strconv.ParseInt(chi.URLParam(r, "submissionID"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	sub, err := app.store.Submissions.GetByID(r.Context(), submissionID)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if sub.UserID != user.ID {
+		app.forbiddenResponse(w, r, fmt.Errorf("user %d cannot view submission %d", user.ID, sub.ID))
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, sub); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+func queryInt(r *http.Request, key string, fallback int) (int, error) {
+	val := r.URL.Query().Get(key)
+	if val == "" {
+		return fallback, nil
+	}
+
+	return strconv.Atoi(val)
+}
@@ -3,16 +3,21 @@ package main
 import (
 	"encoding/json"
 	"fmt"
+	"log"
 	"net/http"
 	"strconv"
 	"ws_practice_1/internal/store"
 
 	"github.com/go-chi/chi/v5"
+	"golang.org/x/crypto/bcrypt"
 )
 
 type userKey string
 
-const userCtx userKey = "user"
+const (
+	userCtx    userKey = "user"
+	sessionCtx userKey = "session"
+)
 
 func (app *application) getUserHandler(w http.ResponseWriter, r *http.Request) {
 	userID := chi.URLParam(r, "userID")
@@ -35,55 +40,138 @@ func (app *application) getUserHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// UpdateUserPayload lists everything a user may change about their
+// account. Changing the email or the password needs the current password
+// as well.
+type UpdateUserPayload struct {
+	Username        *string `json:"username" validate:"omitempty,min=1,max=100"`
+	Email           *string `json:"email" validate:"omitempty,email,max=255"`
+	NewPassword     *string `json:"new_password" validate:"omitempty,min=3,max=72"`
+	CurrentPassword string  `json:"current_password" validate:"required_with=NewPassword,max=72"`
+}
+
 func (app *application) updateUserHandler(w http.ResponseWriter, r *http.Request) {
-	userID := chi.URLParam(r, "userID")
-	userIDInt, err := strconv.Atoi(userID)
-	if err != nil {
+	caller := r.Context().Value(userCtx).(*store.User)
+
+	var payload UpdateUserPayload
+	if err := readJSON(w, r, &payload); err != nil {
 		app.badRequestResponse(w, r, err)
 		return
 	}
 
-	var updates map[string]interface{}
-
-	if err := readJSON(w, r, &updates); err != nil {
+	if err := Validate.Struct(payload); err != nil {
 		app.badRequestResponse(w, r, err)
 		return
 	}
 
-	ctx := r.Context()
+	user, ok := app.userFromURL(w, r)
+	if !ok {
+		return
+	}
 
-	err = app.store.Users.Update(ctx, int64(userIDInt), updates)
-	if err != nil {
-		app.internalServerError(w, r, err)
+	if !canManageUser(caller, user) {
+		app.forbiddenResponse(w, r, fmt.Errorf("user %d cannot update user %d", caller.ID, user.ID))
 		return
 	}
 
-	if err := app.jsonResponse(w, http.StatusOK, "User updated successfully"); err != nil {
+	emailChanged := payload.Email != nil && *payload.Email != user.Email
+
+	// The email and password let whoever controls them into the account,
+	// so changing either needs the current password. Only the owner knows
+	// it, so admins cannot change someone else's.
+	if emailChanged || payload.NewPassword != nil {
+		if caller.ID != user.ID {
+			app.forbiddenResponse(w, r, fmt.Errorf("user %d cannot change the email or password of user %d", caller.ID, user.ID))
+			return
+		}
+
+		if payload.CurrentPassword == "" {
+			app.badRequestResponse(w, r, fmt.Errorf("current_password is required to change the email or password"))
+			return
+		}
+		if err := bcrypt.CompareHashAndPassword(user.Password, []byte(payload.CurrentPassword)); err != nil {
+			app.forbiddenResponse(w, r, fmt.Errorf("current password does not match"))
+			return
+		}
+	}
+
+	if payload.Username != nil {
+		user.Username = *payload.Username
+	}
+	if emailChanged {
+		user.Email = *payload.Email
+	}
+	if payload.NewPassword != nil {
+		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(*payload.NewPassword), bcrypt.DefaultCost)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+		user.Password = hashedPassword
+	}
+
+	if err := app.store.Users.Update(r.Context(), user); err != nil {
+		switch err {
+		case store.ErrDuplicateEmail, store.ErrDuplicateUsername:
+			app.conflictResponse(w, r, err)
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	// Anyone else signed in with the old password is signed out, but the
+	// owner stays signed in here.
+	if payload.NewPassword != nil {
+		session := r.Context().Value(sessionCtx).(*store.Session)
+		if _, err := app.store.Sessions.RevokeOthersForUser(r.Context(), user.ID, session.ID); err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+
+	if emailChanged {
+		if err := app.sendVerificationEmail(r.Context(), user); err != nil {
+			log.Printf("Error sending verification email to user %d: %v\n", user.ID, err)
+		}
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, user); err != nil {
 		app.internalServerError(w, r, err)
 	}
 }
 
 func (app *application) deleteUserHandler(w http.ResponseWriter, r *http.Request) {
-	userID := chi.URLParam(r, "userID")
-	userIDInt, err := strconv.Atoi(userID)
-	if err != nil {
-		app.badRequestResponse(w, r, err)
+	caller := r.Context().Value(userCtx).(*store.User)
+
+	user, ok := app.userFromURL(w, r)
+	if !ok {
 		return
 	}
 
-	ctx := r.Context()
+	if !canManageUser(caller, user) {
+		app.forbiddenResponse(w, r, fmt.Errorf("user %d cannot delete user %d", caller.ID, user.ID))
+		return
+	}
 
-	err = app.store.Users.Delete(ctx, int64(userIDInt))
-	if err != nil {
+	if err := app.store.Users.Delete(r.Context(), user.ID); err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
 
-	if err = app.jsonResponse(w, http.StatusOK, "user deleted successfully"); err != nil {
+	if err := app.jsonResponse(w, http.StatusOK, "user deleted successfully"); err != nil {
 		app.internalServerError(w, r, err)
 	}
 }
 
+// canManageUser reports whether caller may change or delete target's
+// account: their own, or that of anyone an admin may moderate.
+func canManageUser(caller, target *store.User) bool {
+	return caller.ID == target.ID || (caller.HasRole(store.RoleAdmin) && canModerate(caller, target))
+}
+
 func (app *application) getUserStatsHandler(w http.ResponseWriter, r *http.Request) {
 	user, ok := r.Context().Value(userCtx).(*store.User)
 	if !ok || user == nil {
@@ -142,3 +230,27 @@ func (app *application) getTotalMatchesPlayedHandler(w http.ResponseWriter, r *h
 		app.internalServerError(w, r, err)
 	}
 }
+
+func (app *application) getRatingHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(userCtx).(*store.User)
+	if !ok || user == nil {
+		app.unauthorizedErrorResponse(w, r, fmt.Errorf("no user in context"))
+		return
+	}
+
+	history, err := app.store.Ratings.GetHistoryByUser(r.Context(), user.ID)
+	if err != nil {
+		app.internalServerError(w, r, fmt.Errorf("error fetching rating history"))
+		return
+	}
+
+	response := map[string]interface{}{
+		"rating":           user.Rating,
+		"rating_deviation": user.RatingDeviation,
+		"history":          history,
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, response); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
@@ -1,80 +1,86 @@
 package main
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
-	"io"
+	"fmt"
 	"log"
+	"math"
 	"net/http"
-	"strings"
+	"strconv"
 	"sync"
-	"ws_practice_1/internal/env"
+	"time"
+	"ws_practice_1/internal/matchmaking"
 	"ws_practice_1/internal/store"
 
 	"github.com/gorilla/websocket"
 )
 
-var upgrader = websocket.Upgrader{
-	CheckOrigin: func(r *http.Request) bool {
-		return true
-	},
+// client is one user's live socket. gorilla/websocket allows a single
+// concurrent writer, so every write goes through send.
+type client struct {
+	user *store.User
+	conn *websocket.Conn
+	seq  int64
+	mu   sync.Mutex
 }
 
-type Match struct {
-	Player1     *websocket.Conn
-	Player2     *websocket.Conn
-	Question    store.DSAQuestion
-	IsCompleted bool
-	mu          sync.Mutex
-}
+func (c *client) send(ev event) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 
-type wsApp struct {
-	matchMaking MatchMaking
-	matches     map[*websocket.Conn]*Match
-	scores      map[*websocket.Conn]int
-	userConns   map[int64]*websocket.Conn
-	connUsers   map[*websocket.Conn]int64
-	mu          sync.Mutex
-	app         *application
-	userData    map[int64]*store.User
-}
+	c.seq++
+	ev.Version = protocolVersion
+	ev.ID = strconv.FormatInt(c.seq, 10)
 
-type MatchMaking struct {
-	mu      sync.Mutex
-	waiting *websocket.Conn
-}
+	msgJSON, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
 
-type response struct {
-	Type     string      `json:"type"`
-	Message  interface{} `json:"message"`
-	Opponent *Opponent   `json:"opponent,omitempty"`
+	return c.conn.WriteMessage(websocket.TextMessage, msgJSON)
 }
 
-type Opponent struct {
-	Username string `json:"username"`
-	Points   int    `json:"points"`
-}
+func (c *client) sendError(replyTo string, err error) error {
+	wsErr, ok := err.(*wsError)
+	if !ok {
+		log.Printf("Command error for user %d: %v\n", c.user.ID, err)
+		wsErr = newWSError(errCodeInternal, "Something went wrong.")
+	}
 
-type payload struct {
-	Type   string `json:"type"`
-	Answer string `json:"answer"`
-	LangID int    `json:"language_id"`
+	return c.send(replyEvent(replyTo, evtError, wsErr))
 }
 
-const judge0APIURL = "https://judge0-ce.p.rapidapi.com/submissions?base64_encoded=false&wait=true"
-
-type submissionRequest struct {
-	LanguageID int    `json:"language_id"`
-	SourceCode string `json:"source_code"`
-	Stdin      string `json:"stdin"`
+type wsApp struct {
+	queue       *matchmaking.Queue
+	rooms       map[string]int64
+	clients     map[int64]*client
+	matches     map[int64]*Match
+	userMatches map[int64]int64
+	starting    map[int64]bool // players whose match is being set up
+	upgrader    websocket.Upgrader
+	mu          sync.Mutex
+	app         *application
 }
 
-type submissionResponse struct {
-	Stdout   string `json:"stdout"`
-	Stderr   string `json:"stderr"`
-	Message  string `json:"message"`
-	StatusID int    `json:"status_id"`
+func newWSApp(app *application, queue *matchmaking.Queue) *wsApp {
+	return &wsApp{
+		queue:       queue,
+		rooms:       make(map[string]int64),
+		clients:     make(map[int64]*client),
+		matches:     make(map[int64]*Match),
+		userMatches: make(map[int64]int64),
+		starting:    make(map[int64]bool),
+		upgrader: websocket.Upgrader{
+			// Browsers always send an Origin; other clients have no
+			// cookies to abuse and authenticate with a ticket.
+			CheckOrigin: func(r *http.Request) bool {
+				origin := r.Header.Get("Origin")
+				return origin == "" || app.originAllowed(origin)
+			},
+		},
+		app: app,
+	}
 }
 
 func (app *application) wsHandler(w http.ResponseWriter, r *http.Request) {
@@ -85,9 +91,26 @@ func (app *application) wsHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	println("hello")
+	var room *store.Room
+	if code := r.URL.Query().Get("room"); code != "" {
+		var err error
+		room, err = app.joinableRoom(r, code, user)
+		if err != nil {
+			switch err {
+			case store.ErrNotFound:
+				app.notFoundResponse(w, r, err)
+			case errRoomNotYours:
+				app.forbiddenResponse(w, r, err)
+			case errRoomClosed:
+				app.conflictResponse(w, r, err)
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+	}
 
-	conn, err := upgrader.Upgrade(w, r, nil)
+	conn, err := app.ws.upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		log.Println("Upgrade error:", err)
 		return
@@ -95,304 +118,347 @@ func (app *application) wsHandler(w http.ResponseWriter, r *http.Request) {
 
 	log.Printf("WebSocket connection established for user %d\n", user.ID)
 
-	app.ws.mu.Lock()
-
-	if app.ws.userConns == nil {
-		app.ws.userConns = make(map[int64]*websocket.Conn)
-	}
-	if app.ws.connUsers == nil {
-		app.ws.connUsers = make(map[*websocket.Conn]int64)
-	}
-	if app.ws.userData == nil {
-		app.ws.userData = make(map[int64]*store.User)
-	}
-
-	if oldConn, exists := app.ws.userConns[user.ID]; exists {
-		if app.ws.matchMaking.waiting == oldConn {
-			app.ws.matchMaking.mu.Lock()
-			app.ws.matchMaking.waiting = nil
-			app.ws.matchMaking.mu.Unlock()
-		}
+	c := &client{user: user, conn: conn}
 
-		delete(app.ws.connUsers, oldConn)
-		oldConn.Close()
+	app.ws.mu.Lock()
+	oldClient := app.ws.clients[user.ID]
+	app.ws.clients[user.ID] = c
+	// Whatever the old socket was waiting for is dropped, as it would be
+	// on a disconnect; the new one queues or joins a room again.
+	if oldClient != nil {
+		app.ws.stopWaiting(user.ID)
 	}
-
-	app.ws.userConns[user.ID] = conn
-	app.ws.connUsers[conn] = user.ID
-	app.ws.userData[user.ID] = user
 	app.ws.mu.Unlock()
 
-	app.ws.matchPlayers(conn)
-}
-
-func (app *wsApp) matchPlayers(conn *websocket.Conn) {
-	app.mu.Lock()
-	currentUserID := app.connUsers[conn]
-	currentUser := app.userData[currentUserID]
-	app.mu.Unlock()
-
-	if currentUserID == 0 || currentUser == nil {
-		log.Println("Cannot identify user for this connection")
-		return
+	// The old socket's read loop sees it is no longer the user's current
+	// client and exits without touching the match.
+	if oldClient != nil {
+		oldClient.conn.Close()
 	}
 
-	app.matchMaking.mu.Lock()
-	defer app.matchMaking.mu.Unlock()
+	go app.ws.handleMessages(c)
 
-	if app.matchMaking.waiting == nil {
-		app.matchMaking.waiting = conn
-		log.Printf("User %d waiting for opponent...\n", currentUserID)
-		return
+	if !app.ws.resume(c) && room != nil {
+		app.ws.joinRoom(room, user)
 	}
-
-	opponent := app.matchMaking.waiting
-	app.matchMaking.waiting = nil
-
-	app.mu.Lock()
-	waitingUserID := app.connUsers[opponent]
-	waitingUser := app.userData[waitingUserID]
-	app.mu.Unlock()
-
-	if waitingUserID == currentUserID || waitingUser == nil {
-		log.Printf("User %d cannot match with themselves\n", currentUserID)
-		msg := response{Type: "error", Message: "Cannot match with yourself. Please wait for another player."}
-		msgJSON, _ := json.Marshal(msg)
-		conn.WriteMessage(websocket.TextMessage, msgJSON)
-		return
-	}
-
-	question, err := app.app.store.Questions.GetRandomQuestion(context.Background())
-	if err != nil {
-		log.Println("Error fetching random question:", err)
-		return
-	}
-
-	match := &Match{
-		Player1:     conn,
-		Player2:     opponent,
-		Question:    *question,
-		IsCompleted: false,
-	}
-
-	app.mu.Lock()
-	app.matches[conn] = match
-	app.matches[opponent] = match
-	app.scores[conn] = 0
-	app.scores[opponent] = 0
-	app.mu.Unlock()
-
-	log.Printf("Matched users %d and %d\n", currentUserID, waitingUserID)
-
-	msgToCurrentUser := response{
-		Type:    "question",
-		Message: question,
-		Opponent: &Opponent{
-			Username: waitingUser.Username,
-			Points:   waitingUser.Points,
-		},
-	}
-	msgToOpponent := response{
-		Type:    "question",
-		Message: question,
-		Opponent: &Opponent{
-			Username: currentUser.Username,
-			Points:   currentUser.Points,
-		},
-	}
-
-	msgJSON1, _ := json.Marshal(msgToCurrentUser)
-	msgJSON2, _ := json.Marshal(msgToOpponent)
-
-	conn.WriteMessage(websocket.TextMessage, msgJSON1)
-	opponent.WriteMessage(websocket.TextMessage, msgJSON2)
-
-	go app.handleMessages(conn)
-	go app.handleMessages(opponent)
 }
 
-func (app *wsApp) handleMessages(conn *websocket.Conn) {
-	defer conn.Close()
+func (app *wsApp) handleMessages(c *client) {
+	defer c.conn.Close()
 
 	for {
-		_, msg, err := conn.ReadMessage()
+		_, msg, err := c.conn.ReadMessage()
 		if err != nil {
 			log.Println("Read error:", err)
 			break
 		}
 
-		var data payload
-		if err := json.Unmarshal(msg, &data); err != nil {
-			log.Println("Unmarshal error:", err)
+		var cmd command
+		if err := json.Unmarshal(msg, &cmd); err != nil {
+			c.sendError("", newWSError(errCodeBadMessage, "Message is not a valid JSON envelope."))
 			continue
 		}
 
-		if data.Type != "answer" {
+		if err := app.handleCommand(c, cmd); err != nil {
+			c.sendError(cmd.ID, err)
 			continue
 		}
 
-		app.mu.Lock()
-		match := app.matches[conn]
-		app.mu.Unlock()
-
-		if match == nil {
-			log.Println("No active match found.")
-			continue
+		if cmd.Type != cmdPing && cmd.Type != cmdTyping {
+			c.send(replyEvent(cmd.ID, evtAck, nil))
 		}
+	}
 
-		match.mu.Lock()
-		if match.IsCompleted {
-			match.mu.Unlock()
-			log.Println("Challenge already over")
-			continue
-		}
-		match.mu.Unlock()
+	app.disconnect(c)
+}
 
-		stdin := match.Question.ExampleInput
-		expectedOutput := strings.TrimSpace(match.Question.ExampleOutput)
+func (app *wsApp) handleCommand(c *client, cmd command) error {
+	if cmd.Version != protocolVersion {
+		return newWSError(errCodeUnsupportedVersion, fmt.Sprintf("Protocol version %d is required.", protocolVersion))
+	}
 
-		result, err := sendToJudge(data.Answer, data.LangID, stdin)
-		if err != nil {
-			log.Println("Judge0 error:", err)
-			return
+	switch cmd.Type {
+	case cmdJoinQueue:
+		var data joinQueueCommand
+		if len(cmd.Data) > 0 {
+			if err := decodeCommandData(cmd.Data, &data); err != nil {
+				return err
+			}
+		}
+		if err := Validate.Struct(data); err != nil {
+			return newWSError(errCodeBadMessage, err.Error())
 		}
+		return app.joinQueue(c.user.ID, matchmaking.Options{Mode: data.Mode, Difficulty: data.Difficulty, Tag: data.Tag})
+	case cmdLeaveQueue:
+		if !app.queue.Leave(c.user.ID) {
+			return newWSError(errCodeNotQueued, "You are not in the queue.")
+		}
+		return nil
+	case cmdSubmit:
+		var data submitCommand
+		if err := decodeCommandData(cmd.Data, &data); err != nil {
+			return err
+		}
+		return app.submitAnswer(c, cmd.ID, data)
+	case cmdRun:
+		var data runCommand
+		if err := decodeCommandData(cmd.Data, &data); err != nil {
+			return err
+		}
+		return app.runCode(c, cmd.ID, data)
+	case cmdRunSample:
+		var data submitCommand
+		if err := decodeCommandData(cmd.Data, &data); err != nil {
+			return err
+		}
+		return app.runSample(c, cmd.ID, data)
+	case cmdForfeit:
+		return app.resign(c.user.ID)
+	case cmdTyping:
+		return app.noteTyping(c.user.ID)
+	case cmdPing:
+		return c.send(replyEvent(cmd.ID, evtPong, nil))
+	default:
+		return newWSError(errCodeUnknownType, fmt.Sprintf("Unknown message type %q.", cmd.Type))
+	}
+}
 
-		userOutput := strings.TrimSpace(result.Stdout)
+func decodeCommandData(raw json.RawMessage, dst any) error {
+	if len(raw) == 0 {
+		return newWSError(errCodeBadMessage, "Message data is missing.")
+	}
+	if err := json.Unmarshal(raw, dst); err != nil {
+		return newWSError(errCodeBadMessage, "Message data is malformed.")
+	}
+	return nil
+}
 
-		if normalizeOuput(userOutput) == normalizeOuput(expectedOutput) {
-			match.mu.Lock()
-			if !match.IsCompleted {
-				match.IsCompleted = true
+// disconnect forgets a client whose socket closed. A player in a running
+// match gets a grace period to come back before forfeiting.
+func (app *wsApp) disconnect(c *client) {
+	userID := c.user.ID
 
-				app.mu.Lock()
-				opponent := match.Player1
-				if conn == match.Player1 {
-					opponent = match.Player2
-				}
-				winnerID := app.connUsers[conn]
-				loserID := app.connUsers[opponent]
-				app.mu.Unlock()
+	app.mu.Lock()
+	if app.clients[userID] != c {
+		app.mu.Unlock()
+		return
+	}
 
-				log.Println("Correct answer. Challenge over!")
-				go app.app.updatePoints(winnerID, loserID, match.Question.ID)
+	delete(app.clients, userID)
+	app.stopWaiting(userID)
+	match := app.matches[app.userMatches[userID]]
+	app.mu.Unlock()
 
-				winMSG := response{Type: "feedback", Message: "Correct. You won!"}
-				loseMSG := response{Type: "feedback", Message: "You lost!"}
-				winJSON, _ := json.Marshal(winMSG)
-				loseJSON, _ := json.Marshal(loseMSG)
+	if match != nil {
+		app.startGracePeriod(match, userID)
+	}
+}
 
-				conn.WriteMessage(websocket.TextMessage, winJSON)
-				opponent.WriteMessage(websocket.TextMessage, loseJSON)
-			}
-			match.mu.Unlock()
-		} else {
-			feedback := response{Type: "feedback", Message: "Incorrect!"}
-			respJSON, _ := json.Marshal(feedback)
-			conn.WriteMessage(websocket.TextMessage, respJSON)
+// stopWaiting takes the user out of the queue and any room they are waiting
+// in. Callers must hold app.mu.
+func (app *wsApp) stopWaiting(userID int64) {
+	app.queue.Leave(userID)
+	for code, waitingID := range app.rooms {
+		if waitingID == userID {
+			delete(app.rooms, code)
 		}
 	}
+}
 
+// joinRoom parks the first player to arrive in a private room and starts
+// the match once the other one shows up. Rooms never touch the public queue.
+func (app *wsApp) joinRoom(room *store.Room, user *store.User) {
 	app.mu.Lock()
-	match := app.matches[conn]
-	userID := app.connUsers[conn]
+	waitingID, waiting := app.rooms[room.Code]
+	if !waiting || waitingID == user.ID {
+		app.rooms[room.Code] = user.ID
+		app.mu.Unlock()
+
+		log.Printf("User %d waiting in room %s\n", user.ID, room.Code)
+		app.sendToUser(user.ID, newEvent(evtRoomWaiting, room))
+		return
+	}
+	delete(app.rooms, room.Code)
 	app.mu.Unlock()
 
-	if match == nil {
+	ctx := context.Background()
+
+	err := app.app.store.Rooms.UpdateStatus(ctx, room.ID, store.RoomStatusOpen, store.RoomStatusMatched)
+	if err != nil {
+		log.Printf("Room %s could not be started: %v\n", room.Code, err)
+		closed := newEvent(evtError, newWSError(errCodeRoomClosed, "This room is no longer open."))
+		app.sendToUser(user.ID, closed)
+		app.sendToUser(waitingID, closed)
 		return
 	}
 
-	match.mu.Lock()
-	if match.IsCompleted {
-		match.mu.Unlock()
+	mode := modeCasual
+	if room.Rated {
+		mode = modeRanked
+	}
+
+	settings := matchSettings{
+		Mode:       mode,
+		RoomCode:   room.Code,
+		Difficulty: room.Difficulty,
+		TimeLimit:  time.Duration(room.TimeLimitSeconds) * time.Second,
+	}
+
+	err = app.startMatch(waitingID, user.ID, settings)
+	if err == nil {
 		return
 	}
-	match.IsCompleted = true
-	match.mu.Unlock()
 
-	var opponent *websocket.Conn
+	// Reopen the room so the players can join it again.
+	if err := app.app.store.Rooms.UpdateStatus(ctx, room.ID, store.RoomStatusMatched, store.RoomStatusOpen); err != nil {
+		log.Printf("Room %s could not be reopened: %v\n", room.Code, err)
+	}
+
+	if err == errPlayerUnavailable {
+		app.sendToUser(user.ID, newEvent(evtError, newWSError(errCodeOpponentLeft, "Your opponent left the room.")))
+		return
+	}
+
+	log.Printf("Match in room %s failed: %v\n", room.Code, err)
+	app.sendMatchError(err, waitingID, user.ID)
+}
+
+// closeRoom tells whoever is waiting in a cancelled room that it is gone.
+func (app *wsApp) closeRoom(code string) {
 	app.mu.Lock()
-	if match.Player1 == conn {
-		opponent = match.Player2
-	} else {
-		opponent = match.Player1
+	waitingID, waiting := app.rooms[code]
+	delete(app.rooms, code)
+	app.mu.Unlock()
+
+	if waiting {
+		app.sendToUser(waitingID, newEvent(evtRoomCancelled, code))
 	}
-	opponentID := app.connUsers[opponent]
+}
+
+func (app *wsApp) sendToUser(userID int64, ev event) {
+	app.mu.Lock()
+	c := app.clients[userID]
 	app.mu.Unlock()
 
-	winMSG := response{Type: "feedback", Message: "Your opponent disconnected. You won!"}
-	winJSON, _ := json.Marshal(winMSG)
+	if c == nil {
+		return
+	}
 
-	opponent.WriteMessage(websocket.TextMessage, winJSON)
+	if err := c.send(ev); err != nil {
+		log.Printf("Write error for user %d: %v\n", userID, err)
+	}
+}
 
-	go app.app.updatePoints(opponentID, userID, match.Question.ID)
+// joinQueue puts a player who is not already playing into matchmaking.
+// Joining again while queued replaces the options and restarts the wait.
+func (app *wsApp) joinQueue(userID int64, opts matchmaking.Options) error {
+	if opts.Mode == "" {
+		opts.Mode = modeRanked
+	}
 
 	app.mu.Lock()
-	delete(app.matches, conn)
-	delete(app.matches, opponent)
-	delete(app.scores, conn)
-	delete(app.scores, opponent)
+	_, playing := app.userMatches[userID]
+	playing = playing || app.starting[userID]
+	waitingInRoom := false
+	for _, waitingID := range app.rooms {
+		if waitingID == userID {
+			waitingInRoom = true
+		}
+	}
 	app.mu.Unlock()
-}
 
-func sendToJudge(code string, langID int, stdin string) (submissionResponse, error) {
-	reqBody, _ := json.Marshal(submissionRequest{
-		LanguageID: langID,
-		SourceCode: code,
-		Stdin:      stdin,
-	})
+	if playing {
+		return newWSError(errCodeInMatch, "Finish your current match first.")
+	}
+	if waitingInRoom {
+		return newWSError(errCodeInRoom, "You are waiting in a private room.")
+	}
 
-	req, err := http.NewRequest("POST", judge0APIURL, bytes.NewBuffer(reqBody))
+	// The rating is read fresh because it changes after every match while
+	// the session stays open.
+	user, err := app.app.store.Users.GetByID(context.Background(), userID)
 	if err != nil {
-		return submissionResponse{}, nil
+		return err
 	}
 
-	req.Header.Set("X-RapidAPI-Key", env.GetString("RAPID_API_KEY", ""))
-	req.Header.Set("X-RapidAPI-Host", env.GetString("RAPID_API_HOST", ""))
-	req.Header.Set("Content-Type", "application/json")
+	if opts.Mode == modeRanked && !user.EmailVerified() {
+		return newWSError(errCodeEmailUnverified, "Verify your email address to play ranked matches.")
+	}
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return submissionResponse{}, err
+	app.queue.Leave(userID)
+	if _, err := app.queue.Join(userID, int(math.Round(user.Rating)), opts); err != nil {
+		return err
 	}
-	defer resp.Body.Close()
 
-	body, _ := io.ReadAll(resp.Body)
-	var result submissionResponse
-	json.Unmarshal(body, &result)
+	log.Printf("User %d waiting for a %s opponent...\n", userID, opts.Mode)
 
-	return result, nil
-}
+	if status, ok := app.queue.Status(userID); ok {
+		app.sendQueueStatus(status)
+	}
 
-func normalizeOuput(output string) string {
-	output = strings.TrimSpace(output)
-	output = strings.ReplaceAll(output, "\r\n", "\n")
-	output = strings.Join(strings.Fields(output), " ")
-	return output
+	return nil
 }
 
-func (app *application) updatePoints(winnerID, loserID, questionID int64) {
-	ctx := context.Background()
+// runMatchmaker periodically pairs queued players and keeps the ones still
+// waiting informed about their position and estimated wait.
+func (app *wsApp) runMatchmaker(tick, statusInterval time.Duration) {
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
 
-	_, err := app.store.Users.IncrementPoints(ctx, winnerID, 10)
-	if err != nil {
-		log.Println("Error incrementing points:", err)
+	lastStatus := time.Now()
+	for range ticker.C {
+		for _, pair := range app.queue.Match() {
+			app.matchPlayers(pair)
+		}
+
+		if time.Since(lastStatus) < statusInterval {
+			continue
+		}
+		lastStatus = time.Now()
+
+		for _, status := range app.queue.Statuses() {
+			app.sendQueueStatus(status)
+		}
 	}
+}
 
-	_, err = app.store.Users.DecrementPoints(ctx, loserID, 10)
-	if err != nil {
-		log.Println("Error decrementing points:", err)
+func (app *wsApp) sendQueueStatus(status matchmaking.Status) {
+	app.sendToUser(status.UserID, newEvent(evtQueueStatus, queueStatus{
+		Position:             status.Position,
+		QueueSize:            status.QueueSize,
+		RatingWindow:         status.Window,
+		EstimatedWaitSeconds: status.EstimatedWait.Seconds(),
+	}))
+}
+
+func (app *wsApp) matchPlayers(pair matchmaking.Pair) {
+	opts := pair.Options()
+	settings := matchSettings{Mode: opts.Mode, Difficulty: opts.Difficulty, Tag: opts.Tag}
+	err := app.startMatch(pair.A.UserID, pair.B.UserID, settings)
+	if err == nil {
+		return
 	}
 
-	matchResult := store.Match{
-		Player1ID:  winnerID,
-		Player2ID:  loserID,
-		WinnerID:   winnerID,
-		QuestionID: questionID,
+	// The queue has already let go of both players. Any other failure
+	// would most likely repeat on the next tick, so they are told once and
+	// left to queue again themselves.
+	if err != errPlayerUnavailable {
+		log.Printf("Match between users %d and %d failed: %v\n", pair.A.UserID, pair.B.UserID, err)
+		app.sendMatchError(err, pair.A.UserID, pair.B.UserID)
+		return
 	}
 
-	err = app.store.Matches.Create(ctx, &matchResult)
-	if err != nil {
-		log.Println("Error storing match result:", err)
+	log.Printf("Cannot match users %d and %d, requeueing whoever is still connected\n", pair.A.UserID, pair.B.UserID)
+	for _, p := range []matchmaking.Player{pair.A, pair.B} {
+		app.mu.Lock()
+		c := app.clients[p.UserID]
+		app.mu.Unlock()
+
+		if c == nil {
+			continue
+		}
+		if err := app.joinQueue(p.UserID, p.Options); err != nil {
+			log.Printf("User %d could not rejoin the queue: %v\n", p.UserID, err)
+		}
 	}
 }
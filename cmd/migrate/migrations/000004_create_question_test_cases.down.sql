@@ -0,0 +1 @@
+DROP TABLE IF EXISTS question_test_cases;
\ No newline at end of file
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS question_test_cases (
+    id SERIAL PRIMARY KEY,
+    question_id INTEGER NOT NULL REFERENCES dsa_questions(id) ON DELETE CASCADE,
+    input TEXT NOT NULL,
+    expected_output TEXT NOT NULL,
+    is_hidden BOOLEAN NOT NULL DEFAULT true,
+    position INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS idx_question_test_cases_question_id ON question_test_cases(question_id);
+
+INSERT INTO question_test_cases (question_id, input, expected_output, is_hidden, position)
+SELECT id, example_input, example_output, false, 0 FROM dsa_questions;
@@ -0,0 +1,5 @@
+ALTER TABLE dsa_questions
+    DROP COLUMN IF EXISTS checker,
+    DROP COLUMN IF EXISTS checker_tolerance,
+    DROP COLUMN IF EXISTS checker_source,
+    DROP COLUMN IF EXISTS checker_language_id;
@@ -0,0 +1,5 @@
+ALTER TABLE dsa_questions
+    ADD COLUMN IF NOT EXISTS checker VARCHAR(20) NOT NULL DEFAULT 'tokens',
+    ADD COLUMN IF NOT EXISTS checker_tolerance DOUBLE PRECISION NOT NULL DEFAULT 0.000001,
+    ADD COLUMN IF NOT EXISTS checker_source TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS checker_language_id INTEGER NOT NULL DEFAULT 0;
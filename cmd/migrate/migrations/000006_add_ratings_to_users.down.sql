@@ -0,0 +1,6 @@
+ALTER TABLE users ALTER COLUMN points SET DEFAULT 800;
+
+ALTER TABLE users
+    DROP COLUMN IF EXISTS rating,
+    DROP COLUMN IF EXISTS rating_deviation,
+    DROP COLUMN IF EXISTS rating_volatility;
@@ -0,0 +1,10 @@
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS rating DOUBLE PRECISION NOT NULL DEFAULT 1500,
+    ADD COLUMN IF NOT EXISTS rating_deviation DOUBLE PRECISION NOT NULL DEFAULT 350,
+    ADD COLUMN IF NOT EXISTS rating_volatility DOUBLE PRECISION NOT NULL DEFAULT 0.06;
+
+-- Keep existing players' relative standing when moving onto the Glicko scale.
+UPDATE users SET rating = 1500 + (points - 800);
+UPDATE users SET points = ROUND(rating);
+
+ALTER TABLE users ALTER COLUMN points SET DEFAULT 1500;
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS rating_history;
\ No newline at end of file
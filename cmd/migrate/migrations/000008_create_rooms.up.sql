@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS rooms (
+    id SERIAL PRIMARY KEY,
+    code VARCHAR(16) UNIQUE NOT NULL,
+    host_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    guest_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    difficulty VARCHAR(20) NOT NULL DEFAULT '',
+    time_limit_seconds INTEGER NOT NULL DEFAULT 0,
+    status VARCHAR(20) NOT NULL DEFAULT 'open',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_rooms_host_id ON rooms(host_id);
+CREATE INDEX IF NOT EXISTS idx_rooms_guest_id ON rooms(guest_id);
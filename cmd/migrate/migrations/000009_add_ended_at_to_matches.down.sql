@@ -0,0 +1 @@
+ALTER TABLE matches DROP COLUMN IF EXISTS ended_at;
\ No newline at end of file
@@ -0,0 +1,3 @@
+ALTER TABLE matches ADD COLUMN IF NOT EXISTS ended_at TIMESTAMP WITH TIME ZONE;
+
+UPDATE matches SET ended_at = created_at WHERE ended_at IS NULL;
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS submissions;
\ No newline at end of file
@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS submissions (
+    id SERIAL PRIMARY KEY,
+    match_id INTEGER NOT NULL REFERENCES matches(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    question_id INTEGER NOT NULL REFERENCES dsa_questions(id) ON DELETE CASCADE,
+    language_id INTEGER NOT NULL,
+    source_code TEXT NOT NULL,
+    verdict VARCHAR(30) NOT NULL,
+    tests_passed INTEGER NOT NULL DEFAULT 0,
+    total_tests INTEGER NOT NULL DEFAULT 0,
+    stdout TEXT NOT NULL DEFAULT '',
+    stderr TEXT NOT NULL DEFAULT '',
+    runtime_ms INTEGER NOT NULL DEFAULT 0,
+    memory_kb INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_submissions_user_id ON submissions(user_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_submissions_match_id ON submissions(match_id);
@@ -0,0 +1,3 @@
+ALTER TABLE submissions
+DROP COLUMN IF EXISTS compile_output,
+DROP COLUMN IF EXISTS failed_test;
@@ -0,0 +1,3 @@
+ALTER TABLE submissions
+ADD COLUMN IF NOT EXISTS failed_test INTEGER NOT NULL DEFAULT 0,
+ADD COLUMN IF NOT EXISTS compile_output TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,12 @@
+DROP INDEX IF EXISTS idx_matches_player2_question;
+DROP INDEX IF EXISTS idx_matches_player1_question;
+DROP INDEX IF EXISTS idx_dsa_questions_tags;
+DROP INDEX IF EXISTS idx_dsa_questions_difficulty;
+DROP INDEX IF EXISTS idx_dsa_questions_rating;
+
+ALTER TABLE dsa_questions
+DROP COLUMN IF EXISTS memory_limit_kb,
+DROP COLUMN IF EXISTS time_limit_ms,
+DROP COLUMN IF EXISTS tags,
+DROP COLUMN IF EXISTS rating,
+DROP COLUMN IF EXISTS difficulty;
@@ -0,0 +1,13 @@
+ALTER TABLE dsa_questions
+ADD COLUMN IF NOT EXISTS difficulty VARCHAR(20) NOT NULL DEFAULT 'medium',
+ADD COLUMN IF NOT EXISTS rating INTEGER NOT NULL DEFAULT 1500,
+ADD COLUMN IF NOT EXISTS tags TEXT[] NOT NULL DEFAULT '{}',
+ADD COLUMN IF NOT EXISTS time_limit_ms INTEGER NOT NULL DEFAULT 2000,
+ADD COLUMN IF NOT EXISTS memory_limit_kb INTEGER NOT NULL DEFAULT 262144;
+
+CREATE INDEX IF NOT EXISTS idx_dsa_questions_rating ON dsa_questions(rating);
+CREATE INDEX IF NOT EXISTS idx_dsa_questions_difficulty ON dsa_questions(difficulty, rating);
+CREATE INDEX IF NOT EXISTS idx_dsa_questions_tags ON dsa_questions USING GIN(tags);
+
+CREATE INDEX IF NOT EXISTS idx_matches_player1_question ON matches(player1_id, question_id);
+CREATE INDEX IF NOT EXISTS idx_matches_player2_question ON matches(player2_id, question_id);
@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS idx_dsa_questions_active;
+DROP INDEX IF EXISTS idx_dsa_questions_lineage_version;
+
+ALTER TABLE dsa_questions
+DROP COLUMN IF EXISTS created_at,
+DROP COLUMN IF EXISTS retired_at,
+DROP COLUMN IF EXISTS version,
+DROP COLUMN IF EXISTS lineage_id;
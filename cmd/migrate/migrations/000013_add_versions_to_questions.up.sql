@@ -0,0 +1,12 @@
+ALTER TABLE dsa_questions
+ADD COLUMN IF NOT EXISTS lineage_id INTEGER REFERENCES dsa_questions(id),
+ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1,
+ADD COLUMN IF NOT EXISTS retired_at TIMESTAMP WITH TIME ZONE,
+ADD COLUMN IF NOT EXISTS created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now();
+
+UPDATE dsa_questions SET lineage_id = id WHERE lineage_id IS NULL;
+
+ALTER TABLE dsa_questions ALTER COLUMN lineage_id SET NOT NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_dsa_questions_lineage_version ON dsa_questions(lineage_id, version);
+CREATE INDEX IF NOT EXISTS idx_dsa_questions_active ON dsa_questions(rating) WHERE retired_at IS NULL;
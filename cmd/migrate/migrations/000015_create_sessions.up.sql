@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS sessions(
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    refresh_token_hash bytea NOT NULL UNIQUE,
+    previous_token_hash bytea,
+    user_agent text NOT NULL DEFAULT '',
+    expires_at timestamp(0) with time zone NOT NULL,
+    revoked_at timestamp(0) with time zone,
+    last_used_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_sessions_user_id ON sessions(user_id) WHERE revoked_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_sessions_previous_token_hash ON sessions(previous_token_hash);
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS user_identities(
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    provider varchar(50) NOT NULL,
+    subject varchar(255) NOT NULL,
+    email varchar(255) NOT NULL DEFAULT '',
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    UNIQUE (provider, subject),
+    UNIQUE (user_id, provider)
+);
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+	"ws_practice_1/internal/db"
+	"ws_practice_1/internal/env"
+	"ws_practice_1/internal/judge"
+	"ws_practice_1/internal/questionpkg"
+	"ws_practice_1/internal/store"
+)
+
+const usage = `usage: questions <command> [flags] <path>...
+
+commands:
+  validate [-no-judge] path...             check packages and run their reference solutions
+  import [-no-judge] [-update id] path...  validate packages and add them to the question bank
+  export -id id path                       write a question to a package directory or .zip
+
+Packages are directories or .zip files, see internal/questionpkg for the layout.
+The judge backend is configured with the same JUDGE_* variables as the API.
+`
+
+func main() {
+	log.SetFlags(0)
+
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "validate":
+		err = validateCmd(os.Args[2:])
+	case "import":
+		err = importCmd(os.Args[2:])
+	case "export":
+		err = exportCmd(os.Args[2:])
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func validateCmd(args []string) error {
+	fs := flag.NewFlagSet("validate", flag.ExitOnError)
+	noJudge := fs.Bool("no-judge", false, "skip running the reference solution")
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		return fmt.Errorf("validate: no packages given")
+	}
+
+	var j judge.Judge
+	if !*noJudge {
+		var err error
+		if j, err = newJudge(); err != nil {
+			return err
+		}
+	}
+
+	failed := 0
+	for _, path := range fs.Args() {
+		if _, err := loadAndValidate(path, j); err != nil {
+			log.Printf("%s: FAIL\n%v\n", path, err)
+			failed++
+			continue
+		}
+		log.Printf("%s: OK\n", path)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d packages failed validation", failed, fs.NArg())
+	}
+
+	return nil
+}
+
+func importCmd(args []string) error {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	noJudge := fs.Bool("no-judge", false, "skip running the reference solution")
+	update := fs.Int64("update", 0, "publish the package as a new version of this question id")
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		return fmt.Errorf("import: no packages given")
+	}
+	if *update != 0 && fs.NArg() != 1 {
+		return fmt.Errorf("import: -update takes exactly one package")
+	}
+
+	var j judge.Judge
+	if !*noJudge {
+		var err error
+		if j, err = newJudge(); err != nil {
+			return err
+		}
+	}
+
+	// Validate everything first so a bad package does not leave a
+	// half-imported batch behind.
+	var pkgs []*questionpkg.Package
+	for _, path := range fs.Args() {
+		p, err := loadAndValidate(path, j)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		pkgs = append(pkgs, p)
+	}
+
+	storage, closeDB, err := openStore()
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	ctx := context.Background()
+	for i, p := range pkgs {
+		q := p.Question
+		if *update != 0 {
+			q.ID = *update
+			err = storage.Questions.Update(ctx, &q)
+		} else {
+			err = storage.Questions.Create(ctx, &q)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", fs.Arg(i), err)
+		}
+		log.Printf("%s: imported as question %d (lineage %d, version %d)\n", fs.Arg(i), q.ID, q.LineageID, q.Version)
+	}
+
+	return nil
+}
+
+func exportCmd(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	id := fs.Int64("id", 0, "question id to export")
+	fs.Parse(args)
+
+	if *id == 0 || fs.NArg() != 1 {
+		return fmt.Errorf("export: usage is export -id id path")
+	}
+
+	storage, closeDB, err := openStore()
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	ctx := context.Background()
+	q, err := storage.Questions.GetByID(ctx, *id)
+	if err != nil {
+		return err
+	}
+
+	q.TestCases, err = storage.Questions.GetTestCases(ctx, q.ID)
+	if err != nil {
+		return err
+	}
+
+	if err := questionpkg.Write(fs.Arg(0), &questionpkg.Package{Question: *q}); err != nil {
+		return err
+	}
+
+	log.Printf("exported question %d to %s\n", q.ID, fs.Arg(0))
+	return nil
+}
+
+// loadAndValidate loads a package and checks it, running the reference
+// solution when a judge is given.
+func loadAndValidate(path string, j judge.Judge) (*questionpkg.Package, error) {
+	p, err := questionpkg.Load(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+
+	if j == nil {
+		return p, nil
+	}
+
+	results, err := p.Verify(context.Background(), j)
+	for _, r := range results {
+		log.Printf("  test %d: %s (%.2fs, %d KB)\n", r.Index, r.Verdict, r.Time, r.Memory)
+		if r.Verdict != judge.VerdictAccepted && r.Stderr != "" {
+			log.Printf("    %s\n", r.Stderr)
+		}
+	}
+
+	return p, err
+}
+
+func openStore() (store.Storage, func() error, error) {
+	dbConn, err := db.New(
+		env.GetString("DB_USER", "admin"),
+		env.GetString("DB_PASSWORD", "adminpassword"),
+		env.GetString("DB_HOST", "localhost"),
+		env.GetInt("DB_PORT", 5432),
+		env.GetString("DB_NAME", "ws1"),
+		env.GetString("DB_SSL", "disable"),
+		env.GetInt("DB_MAX_CONN_OPEN", 30),
+		env.GetInt("DB_MAX_IDLE_CONNS", 30),
+		env.GetString("DB_MAX_IDLE_TIME", "15m"),
+	)
+	if err != nil {
+		return store.Storage{}, nil, err
+	}
+
+	return store.NewStorage(dbConn), dbConn.Close, nil
+}
+
+func newJudge() (judge.Judge, error) {
+	return judge.New(judge.Config{
+		Backend:   env.GetString("JUDGE_BACKEND", "rapidapi"),
+		URL:       env.GetString("JUDGE0_URL", "http://localhost:2358"),
+		AuthToken: env.GetString("JUDGE0_AUTH_TOKEN", ""),
+		APIKey:    env.GetString("RAPID_API_KEY", ""),
+		APIHost:   env.GetString("RAPID_API_HOST", ""),
+		Timeout:   env.GetDuration("JUDGE_TIMEOUT", 30*time.Second),
+		Local: judge.LocalConfig{
+			WorkDir:       env.GetString("JUDGE_LOCAL_WORK_DIR", ""),
+			TimeLimit:     env.GetDuration("JUDGE_LOCAL_TIME_LIMIT", 2*time.Second),
+			MemoryLimitKB: env.GetInt("JUDGE_LOCAL_MEMORY_LIMIT_KB", 256*1024),
+		},
+	})
+}
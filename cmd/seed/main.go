@@ -4,6 +4,7 @@ import (
 	"log"
 	"ws_practice_1/internal/db"
 	"ws_practice_1/internal/env"
+	"ws_practice_1/internal/questionpkg"
 	"ws_practice_1/internal/store"
 )
 
@@ -24,6 +25,19 @@ func main() {
 	}
 	defer dbConn.Close()
 
+	pkgs, err := questionpkg.LoadAll(env.GetString("SEED_QUESTIONS_DIR", "questions"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var questions []store.DSAQuestion
+	for _, p := range pkgs {
+		if err := p.Validate(); err != nil {
+			log.Fatalf("%s: %v", p.Question.Title, err)
+		}
+		questions = append(questions, p.Question)
+	}
+
 	store := store.NewStorage(dbConn)
-	db.Seed(store, dbConn)
+	db.Seed(store, dbConn, questions)
 }
@@ -40,4 +40,4 @@ func (a *JWTAuthenticator) ValidateToken(token string) (*jwt.Token, error) {
 		jwt.WithIssuer(a.aud),
 		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Name}),
 	)
-}
\ No newline at end of file
+}
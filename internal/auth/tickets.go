@@ -0,0 +1,69 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"sync"
+	"time"
+)
+
+// Ticket stands in for a session where a token cannot be sent in a
+// header, such as a browser opening a WebSocket.
+type Ticket struct {
+	UserID    int64
+	SessionID int64
+	ExpiresAt time.Time
+}
+
+// Tickets issues single-use tickets that expire after a short TTL. They
+// live in memory, so a ticket only works on the instance that issued it.
+type Tickets struct {
+	ttl     time.Duration
+	tickets map[string]Ticket
+	mu      sync.Mutex
+}
+
+func NewTickets(ttl time.Duration) *Tickets {
+	return &Tickets{
+		ttl:     ttl,
+		tickets: make(map[string]Ticket),
+	}
+}
+
+func (t *Tickets) Issue(userID, sessionID int64) (string, Ticket, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", Ticket{}, err
+	}
+	id := base64.RawURLEncoding.EncodeToString(b)
+
+	now := time.Now()
+	ticket := Ticket{UserID: userID, SessionID: sessionID, ExpiresAt: now.Add(t.ttl)}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for k, other := range t.tickets {
+		if !now.Before(other.ExpiresAt) {
+			delete(t.tickets, k)
+		}
+	}
+	t.tickets[id] = ticket
+
+	return id, ticket, nil
+}
+
+// Redeem uses up a ticket. It reports false if the ticket is unknown, was
+// already redeemed or has expired.
+func (t *Tickets) Redeem(id string) (Ticket, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	ticket, ok := t.tickets[id]
+	if !ok {
+		return Ticket{}, false
+	}
+	delete(t.tickets, id)
+
+	return ticket, time.Now().Before(ticket.ExpiresAt)
+}
@@ -4,6 +4,8 @@ import (
 	"log"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -37,3 +39,50 @@ func GetInt(key string, fallback int) int {
 
 	return intVal
 }
+
+func GetDuration(key string, fallback time.Duration) time.Duration {
+	val := os.Getenv(key)
+	if val == "" {
+		return fallback
+	}
+
+	duration, err := time.ParseDuration(val)
+	if err != nil {
+		return fallback
+	}
+
+	return duration
+}
+
+// GetPositiveDuration is GetDuration for values that must be above zero,
+// such as ticker intervals. Zero or negative values fall back.
+func GetPositiveDuration(key string, fallback time.Duration) time.Duration {
+	duration := GetDuration(key, fallback)
+	if duration <= 0 {
+		log.Printf("%s must be positive, using %s\n", key, fallback)
+		return fallback
+	}
+
+	return duration
+}
+
+// GetStrings reads a comma-separated list, ignoring blank entries.
+func GetStrings(key string, fallback []string) []string {
+	val := os.Getenv(key)
+	if val == "" {
+		return fallback
+	}
+
+	var list []string
+	for _, item := range strings.Split(val, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+
+	if len(list) == 0 {
+		return fallback
+	}
+
+	return list
+}
@@ -0,0 +1,135 @@
+package judge
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	CheckerExact                 = "exact"
+	CheckerTokens                = "tokens"
+	CheckerTokensCaseInsensitive = "tokens_ci"
+	CheckerFloat                 = "float"
+	CheckerSpecial               = "special"
+)
+
+const DefaultFloatTolerance = 1e-6
+
+// Checker decides whether a program's output is an acceptable answer for a
+// test case. Special checkers are programs run through a Judge; they get a
+// header line with the byte lengths of the test input, the expected output
+// and the contestant output, followed by those three blobs back to back, and
+// accept by printing "OK" as their first token.
+type Checker struct {
+	Mode       string
+	Tolerance  float64
+	Source     string
+	LanguageID int
+}
+
+func ValidCheckerMode(mode string) bool {
+	switch mode {
+	case CheckerExact, CheckerTokens, CheckerTokensCaseInsensitive, CheckerFloat, CheckerSpecial:
+		return true
+	}
+	return false
+}
+
+func (c Checker) Check(ctx context.Context, j Judge, input, expected, output string) (bool, error) {
+	switch c.Mode {
+	case CheckerExact:
+		return normalizeLines(output) == normalizeLines(expected), nil
+	case "", CheckerTokens:
+		return equalTokens(strings.Fields(output), strings.Fields(expected), false), nil
+	case CheckerTokensCaseInsensitive:
+		return equalTokens(strings.Fields(output), strings.Fields(expected), true), nil
+	case CheckerFloat:
+		return c.equalFloats(strings.Fields(output), strings.Fields(expected)), nil
+	case CheckerSpecial:
+		return c.runSpecial(ctx, j, input, expected, output)
+	default:
+		return false, fmt.Errorf("unknown checker mode %q", c.Mode)
+	}
+}
+
+func (c Checker) runSpecial(ctx context.Context, j Judge, input, expected, output string) (bool, error) {
+	if c.Source == "" {
+		return false, fmt.Errorf("special checker has no source")
+	}
+
+	stdin := fmt.Sprintf("%d %d %d\n%s%s%s", len(input), len(expected), len(output), input, expected, output)
+
+	result, err := j.Execute(ctx, Submission{
+		SourceCode: c.Source,
+		LanguageID: c.LanguageID,
+		Stdin:      stdin,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	if !result.Accepted() {
+		return false, fmt.Errorf("special checker failed: %s %s%s", result.Status.Description, result.CompileOutput, result.Stderr)
+	}
+
+	verdict := strings.Fields(result.Stdout)
+	return len(verdict) > 0 && strings.EqualFold(verdict[0], "OK"), nil
+}
+
+func (c Checker) equalFloats(got, want []string) bool {
+	if len(got) != len(want) {
+		return false
+	}
+
+	tolerance := c.Tolerance
+	if tolerance <= 0 {
+		tolerance = DefaultFloatTolerance
+	}
+
+	for i := range want {
+		w, errW := strconv.ParseFloat(want[i], 64)
+		g, errG := strconv.ParseFloat(got[i], 64)
+		if errW != nil || errG != nil {
+			if got[i] != want[i] {
+				return false
+			}
+			continue
+		}
+
+		if math.IsNaN(g) || math.Abs(g-w) > tolerance*math.Max(1, math.Abs(w)) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func equalTokens(got, want []string, caseInsensitive bool) bool {
+	if len(got) != len(want) {
+		return false
+	}
+
+	for i := range want {
+		if caseInsensitive && strings.EqualFold(got[i], want[i]) {
+			continue
+		}
+		if got[i] != want[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// normalizeLines only forgives line-ending differences and trailing
+// whitespace, which judges add or strip unpredictably.
+func normalizeLines(output string) string {
+	lines := strings.Split(strings.ReplaceAll(output, "\r\n", "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t")
+	}
+	return strings.TrimRight(strings.Join(lines, "\n"), "\n")
+}
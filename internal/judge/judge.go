@@ -0,0 +1,119 @@
+package judge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Status IDs follow Judge0 so every backend reports results the same way.
+const (
+	StatusInQueue             = 1
+	StatusProcessing          = 2
+	StatusAccepted            = 3
+	StatusWrongAnswer         = 4
+	StatusTimeLimitExceeded   = 5
+	StatusCompilationError    = 6
+	StatusRuntimeErrorSIGSEGV = 7
+	StatusRuntimeErrorSIGXFSZ = 8
+	StatusRuntimeErrorSIGFPE  = 9
+	StatusRuntimeErrorSIGABRT = 10
+	StatusRuntimeErrorNZEC    = 11
+	StatusRuntimeErrorOther   = 12
+	StatusInternalError       = 13
+	StatusExecFormatError     = 14
+)
+
+var statusDescriptions = map[int]string{
+	StatusInQueue:             "In Queue",
+	StatusProcessing:          "Processing",
+	StatusAccepted:            "Accepted",
+	StatusWrongAnswer:         "Wrong Answer",
+	StatusTimeLimitExceeded:   "Time Limit Exceeded",
+	StatusCompilationError:    "Compilation Error",
+	StatusRuntimeErrorSIGSEGV: "Runtime Error (SIGSEGV)",
+	StatusRuntimeErrorSIGXFSZ: "Runtime Error (SIGXFSZ)",
+	StatusRuntimeErrorSIGFPE:  "Runtime Error (SIGFPE)",
+	StatusRuntimeErrorSIGABRT: "Runtime Error (SIGABRT)",
+	StatusRuntimeErrorNZEC:    "Runtime Error (NZEC)",
+	StatusRuntimeErrorOther:   "Runtime Error (Other)",
+	StatusInternalError:       "Internal Error",
+	StatusExecFormatError:     "Exec Format Error",
+}
+
+var (
+	ErrUnsupportedLanguage = errors.New("unsupported language")
+	ErrUnknownBackend      = errors.New("unknown judge backend")
+)
+
+// Judge runs a single piece of source code against one stdin and reports
+// what happened. Implementations must be safe for concurrent use.
+type Judge interface {
+	Execute(ctx context.Context, sub Submission) (*Result, error)
+}
+
+type Submission struct {
+	SourceCode string
+	LanguageID int
+	Stdin      string
+	// TimeLimit and MemoryLimitKB override the backend's default limits
+	// when positive.
+	TimeLimit     time.Duration
+	MemoryLimitKB int
+}
+
+type Status struct {
+	ID          int    `json:"id"`
+	Description string `json:"description"`
+}
+
+func NewStatus(id int) Status {
+	return Status{ID: id, Description: statusDescriptions[id]}
+}
+
+type Result struct {
+	Stdout        string  `json:"stdout"`
+	Stderr        string  `json:"stderr"`
+	CompileOutput string  `json:"compile_output"`
+	Message       string  `json:"message"`
+	Status        Status  `json:"status"`
+	Time          float64 `json:"time"`
+	Memory        int     `json:"memory"`
+}
+
+func (r *Result) Accepted() bool {
+	return r.Status.ID == StatusAccepted
+}
+
+// Config selects and sets up a backend for New.
+type Config struct {
+	// Backend is "rapidapi", "judge0" or "local".
+	Backend string
+	// URL and AuthToken are for a self-hosted Judge0.
+	URL       string
+	AuthToken string
+	// APIKey and APIHost are for Judge0 on RapidAPI.
+	APIKey  string
+	APIHost string
+	// Timeout bounds each HTTP request to Judge0.
+	Timeout time.Duration
+	Local   LocalConfig
+}
+
+// New returns the backend named in cfg.
+func New(cfg Config) (Judge, error) {
+	client := &http.Client{Timeout: cfg.Timeout}
+
+	switch cfg.Backend {
+	case "rapidapi":
+		return NewRapidAPIJudge0(cfg.APIKey, cfg.APIHost, client), nil
+	case "judge0":
+		return NewSelfHostedJudge0(cfg.URL, cfg.AuthToken, client), nil
+	case "local":
+		return NewLocalRunner(cfg.Local), nil
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownBackend, cfg.Backend)
+	}
+}
@@ -0,0 +1,123 @@
+package judge
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const rapidAPIURL = "https://judge0-ce.p.rapidapi.com"
+
+// Judge0 talks to a Judge0 CE instance over HTTP, either the hosted
+// RapidAPI endpoint or a self-hosted deployment.
+type Judge0 struct {
+	baseURL string
+	headers map[string]string
+	client  *http.Client
+}
+
+func NewRapidAPIJudge0(apiKey, apiHost string, client *http.Client) *Judge0 {
+	return &Judge0{
+		baseURL: rapidAPIURL,
+		headers: map[string]string{
+			"X-RapidAPI-Key":  apiKey,
+			"X-RapidAPI-Host": apiHost,
+		},
+		client: client,
+	}
+}
+
+func NewSelfHostedJudge0(baseURL, authToken string, client *http.Client) *Judge0 {
+	headers := map[string]string{}
+	if authToken != "" {
+		headers["X-Auth-Token"] = authToken
+	}
+
+	return &Judge0{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		headers: headers,
+		client:  client,
+	}
+}
+
+type judge0Request struct {
+	LanguageID   int     `json:"language_id"`
+	SourceCode   string  `json:"source_code"`
+	Stdin        string  `json:"stdin"`
+	CPUTimeLimit float64 `json:"cpu_time_limit,omitempty"`
+	MemoryLimit  int     `json:"memory_limit,omitempty"`
+}
+
+type judge0Response struct {
+	Stdout        string `json:"stdout"`
+	Stderr        string `json:"stderr"`
+	CompileOutput string `json:"compile_output"`
+	Message       string `json:"message"`
+	Status        Status `json:"status"`
+	Time          string `json:"time"`
+	Memory        int    `json:"memory"`
+}
+
+func (j *Judge0) Execute(ctx context.Context, sub Submission) (*Result, error) {
+	reqBody, err := json.Marshal(judge0Request{
+		LanguageID:   sub.LanguageID,
+		SourceCode:   sub.SourceCode,
+		Stdin:        sub.Stdin,
+		CPUTimeLimit: sub.TimeLimit.Seconds(),
+		MemoryLimit:  sub.MemoryLimitKB,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	url := j.baseURL + "/submissions?base64_encoded=false&wait=true"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewBuffer(reqBody))
+	if err != nil {
+		return nil, err
+	}
+
+	for key, val := range j.headers {
+		req.Header.Set(key, val)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := j.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return nil, fmt.Errorf("judge0 returned %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var out judge0Response
+	if err := json.Unmarshal(body, &out); err != nil {
+		return nil, err
+	}
+
+	result := &Result{
+		Stdout:        out.Stdout,
+		Stderr:        out.Stderr,
+		CompileOutput: out.CompileOutput,
+		Message:       out.Message,
+		Status:        out.Status,
+		Memory:        out.Memory,
+	}
+
+	if out.Time != "" {
+		result.Time, _ = strconv.ParseFloat(out.Time, 64)
+	}
+
+	return result, nil
+}
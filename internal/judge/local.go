@@ -0,0 +1,216 @@
+package judge
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Language describes how the local runner builds and runs one Judge0
+// language ID. Commands may reference {src} and {dir}.
+type Language struct {
+	Name       string
+	SourceFile string
+	Compile    []string
+	Run        []string
+}
+
+var DefaultLanguages = map[int]Language{
+	50: {Name: "C (GCC)", SourceFile: "main.c", Compile: []string{"gcc", "-O2", "-o", "{dir}/main", "{src}", "-lm"}, Run: []string{"{dir}/main"}},
+	54: {Name: "C++ (GCC)", SourceFile: "main.cpp", Compile: []string{"g++", "-O2", "-std=c++17", "-o", "{dir}/main", "{src}"}, Run: []string{"{dir}/main"}},
+	60: {Name: "Go", SourceFile: "main.go", Compile: []string{"go", "build", "-o", "{dir}/main", "{src}"}, Run: []string{"{dir}/main"}},
+	62: {Name: "Java", SourceFile: "Main.java", Compile: []string{"javac", "{src}"}, Run: []string{"java", "-cp", "{dir}", "Main"}},
+	63: {Name: "JavaScript (Node.js)", SourceFile: "main.js", Run: []string{"node", "{src}"}},
+	71: {Name: "Python 3", SourceFile: "main.py", Run: []string{"python3", "{src}"}},
+}
+
+type LocalConfig struct {
+	WorkDir        string
+	TimeLimit      time.Duration
+	CompileTimeout time.Duration
+	MemoryLimitKB  int
+	MaxOutputBytes int
+	Languages      map[int]Language
+}
+
+// LocalRunner compiles and runs code in a subprocess on this machine with
+// CPU, memory and output limits applied through ulimit. It offers no
+// isolation beyond that, so it is meant for dev and CI, not production.
+type LocalRunner struct {
+	cfg LocalConfig
+}
+
+func NewLocalRunner(cfg LocalConfig) *LocalRunner {
+	if cfg.Languages == nil {
+		cfg.Languages = DefaultLanguages
+	}
+	if cfg.TimeLimit <= 0 {
+		cfg.TimeLimit = 2 * time.Second
+	}
+	if cfg.CompileTimeout <= 0 {
+		cfg.CompileTimeout = 30 * time.Second
+	}
+	if cfg.MaxOutputBytes <= 0 {
+		cfg.MaxOutputBytes = 1 << 20
+	}
+
+	return &LocalRunner{cfg: cfg}
+}
+
+func (l *LocalRunner) Execute(ctx context.Context, sub Submission) (*Result, error) {
+	lang, ok := l.cfg.Languages[sub.LanguageID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %d", ErrUnsupportedLanguage, sub.LanguageID)
+	}
+
+	dir, err := os.MkdirTemp(l.cfg.WorkDir, "judge-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	src := filepath.Join(dir, lang.SourceFile)
+	if err := os.WriteFile(src, []byte(sub.SourceCode), 0o600); err != nil {
+		return nil, err
+	}
+
+	if len(lang.Compile) > 0 {
+		compileCtx, cancel := context.WithTimeout(ctx, l.cfg.CompileTimeout)
+		defer cancel()
+
+		cmd := exec.CommandContext(compileCtx, lang.Compile[0], expand(lang.Compile[1:], dir, src)...)
+		cmd.Dir = dir
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			if errors.Is(err, exec.ErrNotFound) {
+				return nil, fmt.Errorf("%w: %s is not installed", ErrUnsupportedLanguage, lang.Compile[0])
+			}
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			return &Result{
+				CompileOutput: string(out),
+				Status:        NewStatus(StatusCompilationError),
+			}, nil
+		}
+	}
+
+	timeLimit := l.cfg.TimeLimit
+	if sub.TimeLimit > 0 {
+		timeLimit = sub.TimeLimit
+	}
+	memoryLimitKB := l.cfg.MemoryLimitKB
+	if sub.MemoryLimitKB > 0 {
+		memoryLimitKB = sub.MemoryLimitKB
+	}
+
+	return l.run(ctx, lang, dir, src, sub.Stdin, timeLimit, memoryLimitKB)
+}
+
+func (l *LocalRunner) run(ctx context.Context, lang Language, dir, src, stdin string, timeLimit time.Duration, memoryLimitKB int) (*Result, error) {
+	runCtx, cancel := context.WithTimeout(ctx, timeLimit)
+	defer cancel()
+
+	// ulimit is applied by a shell wrapper so the limits only affect the
+	// submitted program, not this process.
+	limits := fmt.Sprintf("ulimit -t %d", int(timeLimit.Seconds())+1)
+	if memoryLimitKB > 0 {
+		limits += fmt.Sprintf("; ulimit -v %d", memoryLimitKB)
+	}
+	args := append([]string{"-c", limits + `; exec "$@"`, "sh"}, expand(lang.Run, dir, src)...)
+
+	var stdout, stderr limitedBuffer
+	stdout.limit = l.cfg.MaxOutputBytes
+	stderr.limit = l.cfg.MaxOutputBytes
+
+	cmd := exec.CommandContext(runCtx, "sh", args...)
+	cmd.Dir = dir
+	cmd.Stdin = strings.NewReader(stdin)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	cmd.Env = []string{"PATH=" + os.Getenv("PATH"), "HOME=" + dir}
+
+	start := time.Now()
+	err := cmd.Run()
+	elapsed := time.Since(start)
+
+	result := &Result{
+		Stdout: stdout.String(),
+		Stderr: stderr.String(),
+		Time:   elapsed.Seconds(),
+		Memory: maxRSSKB(cmd.ProcessState),
+	}
+
+	switch {
+	case errors.Is(runCtx.Err(), context.DeadlineExceeded) && ctx.Err() == nil:
+		result.Status = NewStatus(StatusTimeLimitExceeded)
+	case ctx.Err() != nil:
+		return nil, ctx.Err()
+	case err == nil:
+		result.Status = NewStatus(StatusAccepted)
+	default:
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			return nil, err
+		}
+		result.Status = NewStatus(statusFromExit(exitErr))
+		result.Message = exitErr.Error()
+	}
+
+	return result, nil
+}
+
+func statusFromExit(exitErr *exec.ExitError) int {
+	ws, ok := exitErr.Sys().(syscall.WaitStatus)
+	if !ok || !ws.Signaled() {
+		return StatusRuntimeErrorNZEC
+	}
+
+	// Running out of the CPU time set with ulimit -t raises SIGXCPU, and
+	// SIGKILL if the program ignores it.
+	if sig := ws.Signal(); sig == syscall.SIGKILL || sig == cpuLimitSignal {
+		return StatusTimeLimitExceeded
+	}
+
+	switch ws.Signal() {
+	case syscall.SIGSEGV:
+		return StatusRuntimeErrorSIGSEGV
+	case syscall.SIGFPE:
+		return StatusRuntimeErrorSIGFPE
+	case syscall.SIGABRT:
+		return StatusRuntimeErrorSIGABRT
+	default:
+		return StatusRuntimeErrorOther
+	}
+}
+
+func expand(args []string, dir, src string) []string {
+	out := make([]string, len(args))
+	for i, arg := range args {
+		arg = strings.ReplaceAll(arg, "{dir}", dir)
+		out[i] = strings.ReplaceAll(arg, "{src}", src)
+	}
+	return out
+}
+
+type limitedBuffer struct {
+	bytes.Buffer
+	limit int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := b.limit - b.Len(); room < len(p) {
+		if room > 0 {
+			b.Buffer.Write(p[:room])
+		}
+		return len(p), nil
+	}
+	return b.Buffer.Write(p)
+}
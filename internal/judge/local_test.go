@@ -0,0 +1,34 @@
+//go:build unix
+
+package judge
+
+import (
+	"errors"
+	"os/exec"
+	"testing"
+)
+
+func TestStatusFromExit(t *testing.T) {
+	tests := []struct {
+		script string
+		want   int
+	}{
+		{"exit 3", StatusRuntimeErrorNZEC},
+		{"kill -SEGV $$", StatusRuntimeErrorSIGSEGV},
+		{"kill -XCPU $$", StatusTimeLimitExceeded},
+		{"kill -KILL $$", StatusTimeLimitExceeded},
+		{"kill -USR1 $$", StatusRuntimeErrorOther},
+	}
+
+	for _, tt := range tests {
+		err := exec.Command("sh", "-c", tt.script).Run()
+
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			t.Fatalf("%q: error = %v, want an exit error", tt.script, err)
+		}
+		if got := statusFromExit(exitErr); got != tt.want {
+			t.Errorf("%q: status = %d, want %d", tt.script, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package judge
+
+import (
+	"context"
+	"errors"
+	"log"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+const defaultPoolQueueSize = 64
+
+var (
+	ErrPoolBusy        = errors.New("too many submissions waiting")
+	ErrTooManyInFlight = errors.New("too many submissions in flight for this user")
+	ErrPoolClosed      = errors.New("pool is closed")
+)
+
+// Task is one unit of judging work. The context is cancelled once the
+// pool's job timeout elapses.
+type Task func(ctx context.Context)
+
+type PoolConfig struct {
+	// Workers is how many tasks run at the same time.
+	Workers int
+	// QueueSize is how many accepted tasks may wait for a free worker.
+	QueueSize int
+	// PerUser caps the tasks a single user may have queued or running.
+	PerUser int
+	// Timeout bounds how long one task may run. Zero means no limit.
+	Timeout time.Duration
+}
+
+type job struct {
+	userID int64
+	task   Task
+}
+
+// Pool runs judging tasks on a fixed number of workers so a burst of
+// submissions cannot fan out unbounded, and no single user can hog it.
+type Pool struct {
+	cfg      PoolConfig
+	jobs     chan job
+	inFlight map[int64]int
+	closed   bool
+	mu       sync.Mutex
+	wg       sync.WaitGroup
+}
+
+func NewPool(cfg PoolConfig) *Pool {
+	cfg.Workers = max(cfg.Workers, 1)
+	cfg.PerUser = max(cfg.PerUser, 1)
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = defaultPoolQueueSize
+	}
+
+	p := &Pool{
+		cfg:      cfg,
+		jobs:     make(chan job, cfg.QueueSize),
+		inFlight: make(map[int64]int),
+	}
+
+	p.wg.Add(cfg.Workers)
+	for range cfg.Workers {
+		go p.work()
+	}
+
+	return p
+}
+
+// Submit queues a task on behalf of a user. It never blocks: if the user
+// already has PerUser tasks in flight or the queue is full it returns an
+// error straight away.
+func (p *Pool) Submit(userID int64, task Task) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return ErrPoolClosed
+	}
+	if p.inFlight[userID] >= p.cfg.PerUser {
+		return ErrTooManyInFlight
+	}
+
+	select {
+	case p.jobs <- job{userID: userID, task: task}:
+		p.inFlight[userID]++
+		return nil
+	default:
+		return ErrPoolBusy
+	}
+}
+
+// InFlight reports how many tasks a user has queued or running.
+func (p *Pool) InFlight(userID int64) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.inFlight[userID]
+}
+
+// Queued reports how many accepted tasks are waiting for a free worker.
+func (p *Pool) Queued() int {
+	return len(p.jobs)
+}
+
+// Close stops accepting tasks and waits for the queued ones to finish.
+func (p *Pool) Close() {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return
+	}
+	p.closed = true
+	close(p.jobs)
+	p.mu.Unlock()
+
+	p.wg.Wait()
+}
+
+func (p *Pool) work() {
+	defer p.wg.Done()
+
+	for j := range p.jobs {
+		p.run(j)
+	}
+}
+
+func (p *Pool) run(j job) {
+	ctx := context.Background()
+	if p.cfg.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.cfg.Timeout)
+		defer cancel()
+	}
+
+	defer func() {
+		p.mu.Lock()
+		p.inFlight[j.userID]--
+		if p.inFlight[j.userID] <= 0 {
+			delete(p.inFlight, j.userID)
+		}
+		p.mu.Unlock()
+	}()
+
+	// A panicking backend or checker must not take the worker, and with it
+	// the whole server, down.
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Judge task for user %d panicked: %v\n%s", j.userID, r, debug.Stack())
+		}
+	}()
+
+	j.task(ctx)
+}
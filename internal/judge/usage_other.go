@@ -0,0 +1,16 @@
+//go:build !unix
+
+package judge
+
+import (
+	"os"
+	"syscall"
+)
+
+// cpuLimitSignal is sent to a program that used up its CPU time limit.
+// Without SIGXCPU, only the SIGKILL that follows it is seen.
+const cpuLimitSignal = syscall.SIGKILL
+
+func maxRSSKB(state *os.ProcessState) int {
+	return 0
+}
@@ -0,0 +1,24 @@
+//go:build unix
+
+package judge
+
+import (
+	"os"
+	"syscall"
+)
+
+// cpuLimitSignal is sent to a program that used up its CPU time limit.
+const cpuLimitSignal = syscall.SIGXCPU
+
+func maxRSSKB(state *os.ProcessState) int {
+	if state == nil {
+		return 0
+	}
+
+	usage, ok := state.SysUsage().(*syscall.Rusage)
+	if !ok {
+		return 0
+	}
+
+	return int(usage.Maxrss)
+}
@@ -0,0 +1,66 @@
+package judge
+
+import "strings"
+
+const (
+	VerdictAccepted            = "accepted"
+	VerdictWrongAnswer         = "wrong_answer"
+	VerdictCompilationError    = "compilation_error"
+	VerdictRuntimeError        = "runtime_error"
+	VerdictTimeLimitExceeded   = "time_limit_exceeded"
+	VerdictMemoryLimitExceeded = "memory_limit_exceeded"
+	VerdictInternalError       = "internal_error"
+)
+
+// outOfMemoryMarkers are what common runtimes print when an allocation
+// fails under the address space limit, which otherwise looks like any
+// other crash.
+var outOfMemoryMarkers = []string{
+	"MemoryError",
+	"std::bad_alloc",
+	"OutOfMemoryError",
+	"out of memory",
+	"heap out of memory",
+}
+
+// VerdictFor maps a judge result to the verdict shown to players. Judge0
+// has no memory limit status, so a crash is reported as exceeding the
+// memory limit when the program used all of it or its runtime said it ran
+// out. An Accepted run still needs its output checked before it counts as
+// accepted.
+func VerdictFor(result *Result, memoryLimitKB int) string {
+	switch result.Status.ID {
+	case StatusAccepted:
+		if memoryLimitKB > 0 && result.Memory > memoryLimitKB {
+			return VerdictMemoryLimitExceeded
+		}
+		return VerdictAccepted
+	case StatusWrongAnswer:
+		return VerdictWrongAnswer
+	case StatusTimeLimitExceeded:
+		return VerdictTimeLimitExceeded
+	case StatusCompilationError:
+		return VerdictCompilationError
+	case StatusRuntimeErrorSIGSEGV, StatusRuntimeErrorSIGXFSZ, StatusRuntimeErrorSIGFPE,
+		StatusRuntimeErrorSIGABRT, StatusRuntimeErrorNZEC, StatusRuntimeErrorOther, StatusExecFormatError:
+		if outOfMemory(result, memoryLimitKB) {
+			return VerdictMemoryLimitExceeded
+		}
+		return VerdictRuntimeError
+	default:
+		return VerdictInternalError
+	}
+}
+
+func outOfMemory(result *Result, memoryLimitKB int) bool {
+	if memoryLimitKB > 0 && result.Memory >= memoryLimitKB {
+		return true
+	}
+
+	for _, marker := range outOfMemoryMarkers {
+		if strings.Contains(result.Stderr, marker) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,353 @@
+package matchmaking
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+var ErrAlreadyQueued = errors.New("player is already in the queue")
+
+type Config struct {
+	// InitialWindow is the rating difference a player accepts right after
+	// joining. It grows by WindowGrowth every GrowthInterval, up to
+	// MaxWindow (0 means no cap).
+	InitialWindow  int
+	WindowGrowth   int
+	GrowthInterval time.Duration
+	MaxWindow      int
+}
+
+// Options narrow down who a player is willing to be matched with. Players
+// only meet others in the same mode. An empty difficulty or tag accepts any.
+type Options struct {
+	Mode       string
+	Difficulty string
+	Tag        string
+}
+
+func (o Options) compatible(other Options) bool {
+	return o.Mode == other.Mode &&
+		agree(o.Difficulty, other.Difficulty) &&
+		agree(o.Tag, other.Tag)
+}
+
+func agree(a, b string) bool {
+	return a == "" || b == "" || a == b
+}
+
+type Player struct {
+	UserID   int64
+	Rating   int
+	Options  Options
+	JoinedAt time.Time
+}
+
+type Pair struct {
+	A Player
+	B Player
+}
+
+// Options returns the settings both players agreed to, picking the more
+// specific difficulty and tag when only one of them asked for one.
+func (p Pair) Options() Options {
+	opts := p.A.Options
+	if opts.Difficulty == "" {
+		opts.Difficulty = p.B.Options.Difficulty
+	}
+	if opts.Tag == "" {
+		opts.Tag = p.B.Options.Tag
+	}
+	return opts
+}
+
+type Status struct {
+	UserID        int64
+	Position      int
+	Window        int
+	QueueSize     int
+	EstimatedWait time.Duration
+}
+
+// Queue pairs waiting players by rating. It is safe for concurrent use and
+// does no I/O, so callers drive it by calling Match periodically.
+type Queue struct {
+	mu      sync.Mutex
+	cfg     Config
+	waiting map[int64]Player
+	avgWait time.Duration
+	now     func() time.Time
+}
+
+func NewQueue(cfg Config) *Queue {
+	if cfg.GrowthInterval <= 0 {
+		cfg.GrowthInterval = 5 * time.Second
+	}
+
+	return &Queue{
+		cfg:     cfg,
+		waiting: make(map[int64]Player),
+		avgWait: cfg.GrowthInterval,
+		now:     time.Now,
+	}
+}
+
+// WithClock replaces the queue's time source, which lets tests and
+// simulations advance time without sleeping.
+func (q *Queue) WithClock(now func() time.Time) *Queue {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.now = now
+	return q
+}
+
+func (q *Queue) Join(userID int64, rating int, opts Options) (Player, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if _, exists := q.waiting[userID]; exists {
+		return Player{}, ErrAlreadyQueued
+	}
+
+	p := Player{UserID: userID, Rating: rating, Options: opts, JoinedAt: q.now()}
+	q.waiting[userID] = p
+	return p, nil
+}
+
+func (q *Queue) Leave(userID int64) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if _, exists := q.waiting[userID]; !exists {
+		return false
+	}
+
+	delete(q.waiting, userID)
+	return true
+}
+
+func (q *Queue) Contains(userID int64) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	_, exists := q.waiting[userID]
+	return exists
+}
+
+func (q *Queue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return len(q.waiting)
+}
+
+// Window returns how far from their own rating a player is willing to be
+// matched after waiting for the given duration.
+func (q *Queue) Window(waited time.Duration) int {
+	window := q.cfg.InitialWindow + int(waited/q.cfg.GrowthInterval)*q.cfg.WindowGrowth
+	if q.cfg.MaxWindow > 0 && window > q.cfg.MaxWindow {
+		window = q.cfg.MaxWindow
+	}
+	return window
+}
+
+// matchNeighbours is how many compatible players rated just above a player
+// Match considers pairing them with.
+const matchNeighbours = 3
+
+// Match pairs as many waiting players as it can and removes them from the
+// queue. Players are sorted by rating and each is only compared with the
+// few compatible players rated just above them, within the window either
+// side allows. The closest pairs are made first, and on a tie the pair
+// with the longest waiter.
+func (q *Queue) Match() []Pair {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := q.now()
+	players := q.byRating()
+
+	// No pair can be further apart than the longest waiter's window.
+	widest := 0
+	for _, p := range players {
+		widest = max(widest, q.Window(now.Sub(p.JoinedAt)))
+	}
+
+	var candidates []Pair
+	for i, p := range players {
+		found := 0
+		for _, c := range players[i+1:] {
+			if found == matchNeighbours || c.Rating-p.Rating > widest {
+				break
+			}
+			if !p.Options.compatible(c.Options) {
+				continue
+			}
+			found++
+
+			if c.Rating-p.Rating <= max(q.Window(now.Sub(p.JoinedAt)), q.Window(now.Sub(c.JoinedAt))) {
+				candidates = append(candidates, orderPair(p, c))
+			}
+		}
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if da, db := abs(a.A.Rating-a.B.Rating), abs(b.A.Rating-b.B.Rating); da != db {
+			return da < db
+		}
+		return joinedBefore(a.A, b.A)
+	})
+
+	matched := make(map[int64]bool, len(players))
+	var pairs []Pair
+	for _, pair := range candidates {
+		if matched[pair.A.UserID] || matched[pair.B.UserID] {
+			continue
+		}
+		matched[pair.A.UserID] = true
+		matched[pair.B.UserID] = true
+		pairs = append(pairs, pair)
+
+		q.recordWait(now.Sub(pair.A.JoinedAt))
+		q.recordWait(now.Sub(pair.B.JoinedAt))
+		delete(q.waiting, pair.A.UserID)
+		delete(q.waiting, pair.B.UserID)
+	}
+
+	return pairs
+}
+
+// orderPair puts the player who joined first in A.
+func orderPair(p, c Player) Pair {
+	if joinedBefore(c, p) {
+		return Pair{A: c, B: p}
+	}
+	return Pair{A: p, B: c}
+}
+
+// Statuses reports queue position, current window and an estimated wait for
+// every waiting player.
+func (q *Queue) Statuses() []Status {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := q.now()
+	players := q.byJoinTime()
+
+	statuses := make([]Status, 0, len(players))
+	for i, p := range players {
+		statuses = append(statuses, Status{
+			UserID:        p.UserID,
+			Position:      i + 1,
+			Window:        q.Window(now.Sub(p.JoinedAt)),
+			QueueSize:     len(players),
+			EstimatedWait: q.estimateWait(p, players, now),
+		})
+	}
+
+	return statuses
+}
+
+func (q *Queue) Status(userID int64) (Status, bool) {
+	for _, s := range q.Statuses() {
+		if s.UserID == userID {
+			return s, true
+		}
+	}
+	return Status{}, false
+}
+
+// estimateWait is the time until the player can be matched with a
+// compatible opponent already waiting, or the recent average wait when
+// nobody suitable is queued. A pair can be matched once the window of
+// whichever of them joined first covers their rating difference.
+func (q *Queue) estimateWait(p Player, players []Player, now time.Time) time.Duration {
+	best := time.Duration(-1)
+	for _, c := range players {
+		if c.UserID == p.UserID || !p.Options.compatible(c.Options) {
+			continue
+		}
+
+		first := p.JoinedAt
+		if c.JoinedAt.Before(first) {
+			first = c.JoinedAt
+		}
+
+		wait, ok := q.timeToReach(abs(p.Rating-c.Rating), now.Sub(first))
+		if ok && (best == -1 || wait < best) {
+			best = wait
+		}
+	}
+
+	if best == -1 {
+		return q.avgWait
+	}
+	return best
+}
+
+// timeToReach is how much longer a player who has waited for the given
+// time needs until their window covers diff. It reports false if the
+// window never does.
+func (q *Queue) timeToReach(diff int, waited time.Duration) (time.Duration, bool) {
+	if diff <= q.Window(waited) {
+		return 0, true
+	}
+	if q.cfg.WindowGrowth <= 0 || (q.cfg.MaxWindow > 0 && diff > q.cfg.MaxWindow) {
+		return 0, false
+	}
+
+	steps := (diff - q.cfg.InitialWindow + q.cfg.WindowGrowth - 1) / q.cfg.WindowGrowth
+	return max(time.Duration(steps)*q.cfg.GrowthInterval-waited, 0), true
+}
+
+// recordWait keeps an exponential moving average of how long matched
+// players waited.
+func (q *Queue) recordWait(waited time.Duration) {
+	q.avgWait = (q.avgWait*4 + waited) / 5
+}
+
+func (q *Queue) byJoinTime() []Player {
+	players := q.players()
+	sort.Slice(players, func(i, j int) bool {
+		return joinedBefore(players[i], players[j])
+	})
+	return players
+}
+
+func (q *Queue) byRating() []Player {
+	players := q.players()
+	sort.Slice(players, func(i, j int) bool {
+		if players[i].Rating != players[j].Rating {
+			return players[i].Rating < players[j].Rating
+		}
+		return joinedBefore(players[i], players[j])
+	})
+	return players
+}
+
+func (q *Queue) players() []Player {
+	players := make([]Player, 0, len(q.waiting))
+	for _, p := range q.waiting {
+		players = append(players, p)
+	}
+	return players
+}
+
+// joinedBefore orders players by when they joined, and by user ID when
+// they joined at the same time.
+func joinedBefore(a, b Player) bool {
+	if a.JoinedAt.Equal(b.JoinedAt) {
+		return a.UserID < b.UserID
+	}
+	return a.JoinedAt.Before(b.JoinedAt)
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
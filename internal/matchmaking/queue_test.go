@@ -0,0 +1,264 @@
+package matchmaking
+
+import (
+	"testing"
+	"time"
+)
+
+var testConfig = Config{
+	InitialWindow:  100,
+	WindowGrowth:   50,
+	GrowthInterval: 10 * time.Second,
+	MaxWindow:      300,
+}
+
+// fakeClock is a time source the tests move forward by hand.
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func newTestQueue(t *testing.T) (*Queue, *fakeClock) {
+	t.Helper()
+
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}
+	return NewQueue(testConfig).WithClock(clock.Now), clock
+}
+
+func join(t *testing.T, q *Queue, userID int64, rating int, opts Options) {
+	t.Helper()
+
+	if _, err := q.Join(userID, rating, opts); err != nil {
+		t.Fatalf("joining user %d: %v", userID, err)
+	}
+}
+
+func TestWindow(t *testing.T) {
+	q, _ := newTestQueue(t)
+
+	tests := []struct {
+		waited time.Duration
+		want   int
+	}{
+		{0, 100},
+		{9 * time.Second, 100},
+		{10 * time.Second, 150},
+		{25 * time.Second, 200},
+		{40 * time.Second, 300},
+		{time.Hour, 300},
+	}
+
+	for _, tt := range tests {
+		if got := q.Window(tt.waited); got != tt.want {
+			t.Errorf("Window(%s) = %d, want %d", tt.waited, got, tt.want)
+		}
+	}
+}
+
+func TestJoinTwice(t *testing.T) {
+	q, _ := newTestQueue(t)
+
+	join(t, q, 1, 1200, Options{})
+	if _, err := q.Join(1, 1200, Options{}); err != ErrAlreadyQueued {
+		t.Fatalf("second Join error = %v, want %v", err, ErrAlreadyQueued)
+	}
+}
+
+func TestMatchWindowGrowsWithWait(t *testing.T) {
+	q, clock := newTestQueue(t)
+
+	join(t, q, 1, 1000, Options{})
+	join(t, q, 2, 1180, Options{})
+
+	if pairs := q.Match(); len(pairs) != 0 {
+		t.Fatalf("matched %d pairs right after joining, want 0", len(pairs))
+	}
+
+	clock.Advance(10 * time.Second)
+	if pairs := q.Match(); len(pairs) != 0 {
+		t.Fatalf("matched %d pairs with a window of 150, want 0", len(pairs))
+	}
+
+	clock.Advance(10 * time.Second)
+	pairs := q.Match()
+	if len(pairs) != 1 {
+		t.Fatalf("matched %d pairs with a window of 200, want 1", len(pairs))
+	}
+	if pairs[0].A.UserID != 1 || pairs[0].B.UserID != 2 {
+		t.Errorf("paired %d with %d, want 1 with 2", pairs[0].A.UserID, pairs[0].B.UserID)
+	}
+	if q.Len() != 0 {
+		t.Errorf("queue has %d players after matching, want 0", q.Len())
+	}
+}
+
+func TestMatchUsesLongerWaitersWindow(t *testing.T) {
+	q, clock := newTestQueue(t)
+
+	join(t, q, 1, 1000, Options{})
+	clock.Advance(20 * time.Second)
+	join(t, q, 2, 1200, Options{})
+
+	if pairs := q.Match(); len(pairs) != 1 {
+		t.Fatalf("matched %d pairs, want 1", len(pairs))
+	}
+}
+
+func TestMatchPicksClosestRating(t *testing.T) {
+	q, clock := newTestQueue(t)
+
+	join(t, q, 1, 1500, Options{})
+	clock.Advance(time.Second)
+	join(t, q, 2, 1590, Options{})
+	join(t, q, 3, 1520, Options{})
+	join(t, q, 4, 1610, Options{})
+
+	pairs := q.Match()
+	if len(pairs) != 2 {
+		t.Fatalf("matched %d pairs, want 2", len(pairs))
+	}
+
+	got := map[int64]int64{}
+	for _, p := range pairs {
+		got[p.A.UserID] = p.B.UserID
+	}
+	if got[1] != 3 {
+		t.Errorf("user 1 was paired with %d, want 3", got[1])
+	}
+	if got[2] != 4 {
+		t.Errorf("user 2 was paired with %d, want 4", got[2])
+	}
+}
+
+func TestMatchRespectsOptions(t *testing.T) {
+	q, _ := newTestQueue(t)
+
+	join(t, q, 1, 1000, Options{Mode: "ranked", Difficulty: "easy"})
+	join(t, q, 2, 1000, Options{Mode: "ranked", Difficulty: "hard"})
+	join(t, q, 3, 1000, Options{Mode: "casual"})
+	join(t, q, 4, 1000, Options{Mode: "ranked", Tag: "graphs"})
+
+	pairs := q.Match()
+	if len(pairs) != 1 {
+		t.Fatalf("matched %d pairs, want 1", len(pairs))
+	}
+
+	pair := pairs[0]
+	if pair.A.UserID != 1 || pair.B.UserID != 4 {
+		t.Fatalf("paired %d with %d, want 1 with 4", pair.A.UserID, pair.B.UserID)
+	}
+
+	want := Options{Mode: "ranked", Difficulty: "easy", Tag: "graphs"}
+	if got := pair.Options(); got != want {
+		t.Errorf("pair options = %+v, want %+v", got, want)
+	}
+
+	for _, id := range []int64{2, 3} {
+		if !q.Contains(id) {
+			t.Errorf("user %d left the queue without a match", id)
+		}
+	}
+}
+
+func TestStatusEstimatedWait(t *testing.T) {
+	q, clock := newTestQueue(t)
+
+	join(t, q, 1, 1000, Options{})
+
+	// Nobody else is waiting, so the estimate is the starting average.
+	status, ok := q.Status(1)
+	if !ok {
+		t.Fatal("user 1 has no status")
+	}
+	if status.EstimatedWait != testConfig.GrowthInterval {
+		t.Errorf("wait alone = %s, want %s", status.EstimatedWait, testConfig.GrowthInterval)
+	}
+
+	// 220 apart needs a window of 250, reached after three growth steps.
+	join(t, q, 2, 1220, Options{})
+	clock.Advance(5 * time.Second)
+
+	status, _ = q.Status(1)
+	if want := 25 * time.Second; status.EstimatedWait != want {
+		t.Errorf("wait for opponent = %s, want %s", status.EstimatedWait, want)
+	}
+	if status.Position != 1 || status.QueueSize != 2 || status.Window != 100 {
+		t.Errorf("status = %+v, want position 1 of 2 with window 100", status)
+	}
+
+	clock.Advance(25 * time.Second)
+	status, _ = q.Status(1)
+	if status.EstimatedWait != 0 {
+		t.Errorf("wait once in range = %s, want 0", status.EstimatedWait)
+	}
+
+	// Beyond the maximum window the estimate falls back to the average.
+	join(t, q, 3, 5000, Options{})
+	status, _ = q.Status(3)
+	if status.EstimatedWait != testConfig.GrowthInterval {
+		t.Errorf("wait out of reach = %s, want %s", status.EstimatedWait, testConfig.GrowthInterval)
+	}
+}
+
+func TestStatusUsesLongerWaitersWindow(t *testing.T) {
+	q, clock := newTestQueue(t)
+
+	join(t, q, 1, 1000, Options{})
+	clock.Advance(15 * time.Second)
+	join(t, q, 2, 1200, Options{})
+
+	// User 1's window reaches 200 after 20 seconds, well before user 2's.
+	status, _ := q.Status(2)
+	if want := 5 * time.Second; status.EstimatedWait != want {
+		t.Errorf("wait = %s, want %s", status.EstimatedWait, want)
+	}
+}
+
+func TestMatchPairsRatingNeighbours(t *testing.T) {
+	q, _ := newTestQueue(t)
+
+	ratings := map[int64]int{1: 1000, 2: 1400, 3: 1050, 4: 1800, 5: 1440, 6: 1830}
+	for id, rating := range ratings {
+		join(t, q, id, rating, Options{})
+	}
+
+	got := map[int64]int64{}
+	for _, p := range q.Match() {
+		got[p.A.UserID] = p.B.UserID
+		got[p.B.UserID] = p.A.UserID
+	}
+
+	want := map[int64]int64{1: 3, 2: 5, 4: 6}
+	for a, b := range want {
+		if got[a] != b {
+			t.Errorf("user %d was paired with %d, want %d", a, got[a], b)
+		}
+	}
+}
+
+func TestMatchUpdatesAverageWait(t *testing.T) {
+	q, clock := newTestQueue(t)
+
+	join(t, q, 1, 1000, Options{})
+	join(t, q, 2, 1000, Options{})
+	clock.Advance(20 * time.Second)
+	if pairs := q.Match(); len(pairs) != 1 {
+		t.Fatalf("matched %d pairs, want 1", len(pairs))
+	}
+
+	join(t, q, 3, 1000, Options{})
+	status, _ := q.Status(3)
+
+	// Starting from 10s, two waits of 20s move the average to 12s and
+	// then 13.6s.
+	if want := 13600 * time.Millisecond; status.EstimatedWait != want {
+		t.Errorf("average wait = %s, want %s", status.EstimatedWait, want)
+	}
+}
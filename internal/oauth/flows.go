@@ -0,0 +1,92 @@
+package oauth
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"sync"
+	"time"
+)
+
+// Flow is a sign-in that was sent to a provider and has not come back yet.
+type Flow struct {
+	Provider string
+	Verifier string
+	// Nonce ties the provider's ID token to this flow.
+	Nonce string
+	// UserID is the signed-in user the identity is linked to, or 0 when the
+	// flow signs someone in.
+	UserID    int64
+	ExpiresAt time.Time
+}
+
+// Flows remembers pending sign-ins by their state parameter until the
+// provider redirects back. Like auth.Tickets they live in memory, so the
+// callback has to reach the instance that started the flow.
+type Flows struct {
+	ttl   time.Duration
+	flows map[string]Flow
+	mu    sync.Mutex
+}
+
+func NewFlows(ttl time.Duration) *Flows {
+	return &Flows{
+		ttl:   ttl,
+		flows: make(map[string]Flow),
+	}
+}
+
+// Start records a new flow and returns its state.
+func (f *Flows) Start(provider string, userID int64) (string, Flow, error) {
+	state, err := randomString()
+	if err != nil {
+		return "", Flow{}, err
+	}
+
+	verifier, err := NewVerifier()
+	if err != nil {
+		return "", Flow{}, err
+	}
+
+	nonce, err := randomString()
+	if err != nil {
+		return "", Flow{}, err
+	}
+
+	now := time.Now()
+	flow := Flow{Provider: provider, Verifier: verifier, Nonce: nonce, UserID: userID, ExpiresAt: now.Add(f.ttl)}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for k, other := range f.flows {
+		if !now.Before(other.ExpiresAt) {
+			delete(f.flows, k)
+		}
+	}
+	f.flows[state] = flow
+
+	return state, flow, nil
+}
+
+// Finish uses up the flow with the given state. It reports false if the
+// state is unknown, was already used or has expired.
+func (f *Flows) Finish(state string) (Flow, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	flow, ok := f.flows[state]
+	if !ok {
+		return Flow{}, false
+	}
+	delete(f.flows, state)
+
+	return flow, time.Now().Before(flow.ExpiresAt)
+}
+
+func randomString() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,38 @@
+package oauth
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFlowsFinishOnce(t *testing.T) {
+	flows := NewFlows(time.Minute)
+
+	state, started, err := flows.Start("oidc", 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if started.Verifier == "" || started.Nonce == "" || started.Nonce == started.Verifier {
+		t.Fatalf("flow %+v needs its own verifier and nonce", started)
+	}
+
+	finished, ok := flows.Finish(state)
+	if !ok || finished != started {
+		t.Fatalf("Finish = %+v, %v, want %+v, true", finished, ok, started)
+	}
+	if _, ok := flows.Finish(state); ok {
+		t.Fatal("a flow finished twice")
+	}
+}
+
+func TestFlowsExpire(t *testing.T) {
+	flows := NewFlows(-time.Second)
+
+	state, _, err := flows.Start("oidc", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := flows.Finish(state); ok {
+		t.Fatal("an expired flow finished")
+	}
+}
@@ -0,0 +1,69 @@
+package oauth
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+)
+
+const (
+	githubAuthURL     = "https://github.com/login/oauth/authorize"
+	githubTokenURL    = "https://github.com/login/oauth/access_token"
+	githubUserInfoURL = "https://api.github.com/user"
+)
+
+// NewGitHub returns a provider for GitHub, which speaks plain OAuth 2.0
+// rather than OpenID Connect. Unset endpoints default to github.com.
+func NewGitHub(cfg Config, client *http.Client) *Provider {
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{"read:user", "user:email"}
+	}
+	if cfg.AuthURL == "" {
+		cfg.AuthURL = githubAuthURL
+	}
+	if cfg.TokenURL == "" {
+		cfg.TokenURL = githubTokenURL
+	}
+	if cfg.UserInfoURL == "" {
+		cfg.UserInfoURL = githubUserInfoURL
+	}
+
+	p := &Provider{cfg: cfg, client: client}
+	p.profile = p.githubProfile
+	return p
+}
+
+// githubProfile reads the user and their verified primary email. The email
+// on the user itself is only the public one and may be missing or stale.
+func (p *Provider) githubProfile(ctx context.Context, accessToken string) (*Profile, error) {
+	var user struct {
+		ID    int64  `json:"id"`
+		Login string `json:"login"`
+	}
+	if err := p.getJSON(ctx, p.cfg.UserInfoURL, accessToken, &user); err != nil {
+		return nil, err
+	}
+
+	var emails []struct {
+		Email    string `json:"email"`
+		Primary  bool   `json:"primary"`
+		Verified bool   `json:"verified"`
+	}
+	if err := p.getJSON(ctx, p.cfg.UserInfoURL+"/emails", accessToken, &emails); err != nil {
+		return nil, err
+	}
+
+	profile := &Profile{Username: user.Login}
+	if user.ID != 0 {
+		profile.Subject = strconv.FormatInt(user.ID, 10)
+	}
+
+	for _, e := range emails {
+		if e.Primary && e.Verified {
+			profile.Email = e.Email
+			profile.EmailVerified = true
+		}
+	}
+
+	return profile, nil
+}
@@ -0,0 +1,131 @@
+package oauth
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// idTokenLeeway allows for clocks that are slightly out of step with the
+// provider's.
+const idTokenLeeway = time.Minute
+
+type idTokenClaims struct {
+	Nonce string `json:"nonce"`
+	jwt.RegisteredClaims
+}
+
+// verifyIDToken checks that the ID token was signed by the provider, was
+// issued to this client for the flow with the given nonce, and has not
+// expired. It returns the subject the token is about.
+func (p *Provider) verifyIDToken(ctx context.Context, raw, nonce string) (string, error) {
+	if raw == "" {
+		return "", errors.New("oauth: token endpoint returned no id token")
+	}
+
+	claims := &idTokenClaims{}
+	_, err := jwt.ParseWithClaims(raw, claims, func(t *jwt.Token) (any, error) {
+		kid, _ := t.Header["kid"].(string)
+		return p.publicKey(ctx, kid)
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Name}),
+		jwt.WithIssuer(p.cfg.Issuer),
+		jwt.WithAudience(p.cfg.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(idTokenLeeway),
+	)
+	if err != nil {
+		return "", fmt.Errorf("%w: invalid id token: %v", ErrDenied, err)
+	}
+
+	if nonce == "" || claims.Nonce != nonce {
+		return "", fmt.Errorf("%w: id token nonce does not match", ErrDenied)
+	}
+	if claims.Subject == "" {
+		return "", errors.New("oauth: id token has no subject")
+	}
+
+	return claims.Subject, nil
+}
+
+// publicKey returns the provider's signing key with the given ID. The key
+// set is fetched again when the ID is unknown, since providers rotate
+// their keys.
+func (p *Provider) publicKey(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+
+	keys, err := p.fetchKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+	p.keys = keys
+
+	key, ok := keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("oauth: provider has no signing key %q", kid)
+	}
+	return key, nil
+}
+
+// fetchKeys reads the RSA signing keys from the provider's JWKS endpoint.
+func (p *Provider) fetchKeys(ctx context.Context) (map[string]*rsa.PublicKey, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.cfg.JWKSURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	var set struct {
+		Keys []struct {
+			Kid string `json:"kid"`
+			Kty string `json:"kty"`
+			Use string `json:"use"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	status, err := p.do(req, &set)
+	if err != nil {
+		return nil, fmt.Errorf("oauth: fetching signing keys: %w", err)
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("oauth: %s returned %d", p.cfg.JWKSURL, status)
+	}
+
+	keys := make(map[string]*rsa.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" || (k.Use != "" && k.Use != "sig") {
+			continue
+		}
+
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("oauth: signing key %q: %w", k.Kid, err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("oauth: signing key %q: %w", k.Kid, err)
+		}
+
+		exponent := new(big.Int).SetBytes(e)
+		if !exponent.IsInt64() || exponent.Int64() > 1<<31-1 {
+			return nil, fmt.Errorf("oauth: signing key %q has an invalid exponent", k.Kid)
+		}
+
+		keys[k.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}
+	}
+
+	return keys, nil
+}
@@ -0,0 +1,330 @@
+// Package oauth signs users in with OAuth 2.0 and OpenID Connect providers
+// using the authorization code flow with PKCE.
+//
+// The user's profile is read from the provider's userinfo endpoint with the
+// access token. OpenID Connect providers must also return an ID token, which
+// is checked against their published signing keys and has to be about the
+// same user.
+package oauth
+
+import (
+	"context"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ErrDenied is returned when the provider rejects the request, for example
+// because the code was already used or has expired.
+var ErrDenied = errors.New("oauth: provider denied the request")
+
+const maxResponseSize = 1 << 20
+
+// Config describes one provider. For OpenID Connect providers only the
+// Issuer is needed and the endpoints are discovered from it; endpoints that
+// are set take precedence.
+type Config struct {
+	ClientID     string
+	ClientSecret string
+	// RedirectURL is the callback registered with the provider.
+	RedirectURL string
+	Scopes      []string
+	Issuer      string
+	AuthURL     string
+	TokenURL    string
+	UserInfoURL string
+	JWKSURL     string
+}
+
+// Profile is what the provider knows about the signed-in user. Subject is
+// the provider's stable ID for them and never changes, unlike the email.
+type Profile struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Username      string
+}
+
+type Provider struct {
+	cfg     Config
+	client  *http.Client
+	profile func(ctx context.Context, accessToken string) (*Profile, error)
+
+	// oidc is set for OpenID Connect providers, whose ID tokens are
+	// verified.
+	oidc bool
+
+	mu         sync.Mutex
+	discovered bool
+	keys       map[string]*rsa.PublicKey
+}
+
+// NewOIDC returns a provider that speaks OpenID Connect. Discovery happens
+// on first use, so a provider that is down does not stop the server from
+// starting.
+func NewOIDC(cfg Config, client *http.Client) *Provider {
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{"openid", "email", "profile"}
+	}
+
+	p := &Provider{cfg: cfg, client: client, oidc: true}
+	p.profile = p.oidcProfile
+	return p
+}
+
+// NewVerifier returns a random PKCE code verifier.
+func NewVerifier() (string, error) {
+	return randomString()
+}
+
+// AuthCodeURL returns the provider page the user is sent to. state comes
+// back unchanged on the callback, and verifier and nonce are needed again by
+// Exchange.
+func (p *Provider) AuthCodeURL(ctx context.Context, state, verifier, nonce string) (string, error) {
+	if err := p.discover(ctx); err != nil {
+		return "", err
+	}
+
+	challenge := sha256.Sum256([]byte(verifier))
+
+	params := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.cfg.ClientID},
+		"redirect_uri":          {p.cfg.RedirectURL},
+		"scope":                 {strings.Join(p.cfg.Scopes, " ")},
+		"state":                 {state},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+	if p.oidc {
+		params.Set("nonce", nonce)
+	}
+
+	sep := "?"
+	if strings.Contains(p.cfg.AuthURL, "?") {
+		sep = "&"
+	}
+
+	return p.cfg.AuthURL + sep + params.Encode(), nil
+}
+
+// Exchange trades the code from the callback for an access token and uses
+// it to fetch the user's profile.
+func (p *Provider) Exchange(ctx context.Context, code, verifier, nonce string) (*Profile, error) {
+	if err := p.discover(ctx); err != nil {
+		return nil, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.cfg.RedirectURL},
+		"client_id":     {p.cfg.ClientID},
+		"client_secret": {p.cfg.ClientSecret},
+		"code_verifier": {verifier},
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.cfg.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	var token struct {
+		AccessToken      string `json:"access_token"`
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	// GitHub reports errors with a 200, so the body is read either way.
+	status, err := p.do(req, &token)
+	if err != nil {
+		return nil, err
+	}
+	if token.Error != "" {
+		return nil, fmt.Errorf("%w: %s", ErrDenied, strings.TrimSpace(token.Error+" "+token.ErrorDescription))
+	}
+	if status != http.StatusOK || token.AccessToken == "" {
+		return nil, fmt.Errorf("oauth: token endpoint returned %d without an access token", status)
+	}
+
+	var subject string
+	if p.oidc {
+		if subject, err = p.verifyIDToken(ctx, token.IDToken, nonce); err != nil {
+			return nil, err
+		}
+	}
+
+	profile, err := p.profile(ctx, token.AccessToken)
+	if err != nil {
+		return nil, err
+	}
+	if profile.Subject == "" {
+		return nil, errors.New("oauth: profile has no subject")
+	}
+	if p.oidc && profile.Subject != subject {
+		return nil, fmt.Errorf("%w: userinfo subject does not match the id token", ErrDenied)
+	}
+
+	return profile, nil
+}
+
+// discover fills in the endpoints from the issuer's OpenID configuration.
+// A failed attempt is retried on the next call.
+func (p *Provider) discover(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.discovered {
+		return nil
+	}
+	// ID tokens name the issuer, so it cannot be left out.
+	if p.oidc && p.cfg.Issuer == "" {
+		return errors.New("oauth: OpenID Connect provider needs an issuer")
+	}
+	if p.hasEndpoints() {
+		p.discovered = true
+		return nil
+	}
+	if p.cfg.Issuer == "" {
+		return errors.New("oauth: provider needs an issuer or all of its endpoints")
+	}
+
+	issuer := strings.TrimSuffix(p.cfg.Issuer, "/")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, issuer+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return err
+	}
+
+	var doc struct {
+		Issuer                string `json:"issuer"`
+		AuthorizationEndpoint string `json:"authorization_endpoint"`
+		TokenEndpoint         string `json:"token_endpoint"`
+		UserInfoEndpoint      string `json:"userinfo_endpoint"`
+		JWKSURI               string `json:"jwks_uri"`
+	}
+	status, err := p.do(req, &doc)
+	if err != nil {
+		return fmt.Errorf("oauth: discovery: %w", err)
+	}
+	if status != http.StatusOK {
+		return fmt.Errorf("oauth: discovery returned %d", status)
+	}
+	if strings.TrimSuffix(doc.Issuer, "/") != issuer {
+		return fmt.Errorf("oauth: discovery issuer %q does not match %q", doc.Issuer, p.cfg.Issuer)
+	}
+
+	if p.cfg.AuthURL == "" {
+		p.cfg.AuthURL = doc.AuthorizationEndpoint
+	}
+	if p.cfg.TokenURL == "" {
+		p.cfg.TokenURL = doc.TokenEndpoint
+	}
+	if p.cfg.UserInfoURL == "" {
+		p.cfg.UserInfoURL = doc.UserInfoEndpoint
+	}
+	if p.cfg.JWKSURL == "" {
+		p.cfg.JWKSURL = doc.JWKSURI
+	}
+	if !p.hasEndpoints() {
+		return errors.New("oauth: discovery is missing an endpoint")
+	}
+
+	// ID tokens carry the issuer exactly as the provider spells it.
+	p.cfg.Issuer = doc.Issuer
+	p.discovered = true
+	return nil
+}
+
+func (p *Provider) hasEndpoints() bool {
+	return p.cfg.AuthURL != "" && p.cfg.TokenURL != "" && p.cfg.UserInfoURL != "" &&
+		(!p.oidc || p.cfg.JWKSURL != "")
+}
+
+func (p *Provider) oidcProfile(ctx context.Context, accessToken string) (*Profile, error) {
+	var info struct {
+		Subject           string          `json:"sub"`
+		Email             string          `json:"email"`
+		EmailVerified     json.RawMessage `json:"email_verified"`
+		PreferredUsername string          `json:"preferred_username"`
+		Nickname          string          `json:"nickname"`
+		Name              string          `json:"name"`
+	}
+	if err := p.getJSON(ctx, p.cfg.UserInfoURL, accessToken, &info); err != nil {
+		return nil, err
+	}
+
+	// Some providers send email_verified as a string.
+	verified, _ := strconv.ParseBool(strings.Trim(string(info.EmailVerified), `"`))
+
+	username := info.PreferredUsername
+	if username == "" {
+		username = info.Nickname
+	}
+	if username == "" {
+		username = info.Name
+	}
+
+	return &Profile{
+		Subject:       info.Subject,
+		Email:         info.Email,
+		EmailVerified: verified && info.Email != "",
+		Username:      username,
+	}, nil
+}
+
+// getJSON fetches url with the access token and decodes the response.
+func (p *Provider) getJSON(ctx context.Context, url, accessToken string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Accept", "application/json")
+
+	status, err := p.do(req, v)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return fmt.Errorf("%w: %s returned %d", ErrDenied, url, status)
+	case status != http.StatusOK:
+		return fmt.Errorf("oauth: %s returned %d", url, status)
+	}
+
+	return nil
+}
+
+// do sends the request and decodes a JSON body into v when there is one.
+func (p *Provider) do(req *http.Request, v any) (int, error) {
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return 0, err
+	}
+
+	if resp.StatusCode == http.StatusOK || strings.Contains(resp.Header.Get("Content-Type"), "json") {
+		if err := json.Unmarshal(body, v); err != nil && resp.StatusCode == http.StatusOK {
+			return 0, fmt.Errorf("oauth: decoding %s: %w", req.URL.Path, err)
+		}
+	}
+
+	return resp.StatusCode, nil
+}
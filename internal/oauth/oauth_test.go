@@ -0,0 +1,323 @@
+package oauth_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+	"ws_practice_1/internal/oauth"
+	"ws_practice_1/internal/oauth/oauthtest"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const redirectURL = "http://localhost:8080/api/v1/authentication/oauth/oidc/callback"
+
+var testUser = oauthtest.User{
+	Subject:       "user-123",
+	Email:         "ada@example.com",
+	EmailVerified: true,
+	Username:      "ada",
+}
+
+func newProvider(srv *oauthtest.Server) *oauth.Provider {
+	return oauth.NewOIDC(oauth.Config{
+		ClientID:     oauthtest.ClientID,
+		ClientSecret: oauthtest.ClientSecret,
+		RedirectURL:  redirectURL,
+		Issuer:       srv.Issuer(),
+	}, srv.Client())
+}
+
+// signIn runs a whole flow: the user approves at the provider, which sends
+// them back with a code that is then exchanged.
+func signIn(t *testing.T, srv *oauthtest.Server, p *oauth.Provider) (*oauth.Profile, error) {
+	t.Helper()
+
+	verifier, err := oauth.NewVerifier()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	authURL, err := p.AuthCodeURL(context.Background(), "some-state", verifier, "some-nonce")
+	if err != nil {
+		t.Fatalf("AuthCodeURL: %v", err)
+	}
+
+	code := authorize(t, srv, authURL)
+	return p.Exchange(context.Background(), code, verifier, "some-nonce")
+}
+
+func authorize(t *testing.T, srv *oauthtest.Server, authURL string) string {
+	t.Helper()
+
+	callback, err := srv.Authorize(authURL)
+	if err != nil {
+		t.Fatalf("authorizing: %v", err)
+	}
+
+	u, err := url.Parse(callback)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := u.Query().Get("state"); got != "some-state" {
+		t.Fatalf("callback state = %q, want %q", got, "some-state")
+	}
+
+	return u.Query().Get("code")
+}
+
+func TestAuthCodeURLUsesDiscoveredEndpoint(t *testing.T) {
+	srv := oauthtest.NewServer(t, testUser)
+	p := newProvider(srv)
+
+	authURL, err := p.AuthCodeURL(context.Background(), "some-state", "the-verifier", "some-nonce")
+	if err != nil {
+		t.Fatalf("AuthCodeURL: %v", err)
+	}
+
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := u.Scheme+"://"+u.Host+u.Path, srv.URL+"/authorize"; got != want {
+		t.Errorf("auth endpoint = %s, want %s", got, want)
+	}
+
+	challenge := sha256.Sum256([]byte("the-verifier"))
+	want := map[string]string{
+		"response_type":         "code",
+		"client_id":             oauthtest.ClientID,
+		"redirect_uri":          redirectURL,
+		"scope":                 "openid email profile",
+		"state":                 "some-state",
+		"nonce":                 "some-nonce",
+		"code_challenge":        base64.RawURLEncoding.EncodeToString(challenge[:]),
+		"code_challenge_method": "S256",
+	}
+	for key, value := range want {
+		if got := u.Query().Get(key); got != value {
+			t.Errorf("%s = %q, want %q", key, got, value)
+		}
+	}
+}
+
+func TestDiscoveryIssuerMismatch(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"issuer": "https://evil.example.com", "authorization_endpoint": "https://evil.example.com/authorize",
+			"token_endpoint": "https://evil.example.com/token", "userinfo_endpoint": "https://evil.example.com/userinfo",
+			"jwks_uri": "https://evil.example.com/jwks"}`))
+	}))
+	defer srv.Close()
+
+	p := oauth.NewOIDC(oauth.Config{ClientID: oauthtest.ClientID, Issuer: srv.URL}, srv.Client())
+
+	if _, err := p.AuthCodeURL(context.Background(), "state", "verifier", "nonce"); err == nil {
+		t.Fatal("AuthCodeURL succeeded with a mismatched issuer")
+	}
+}
+
+func TestOIDCNeedsIssuer(t *testing.T) {
+	p := oauth.NewOIDC(oauth.Config{
+		ClientID:    oauthtest.ClientID,
+		AuthURL:     "https://idp.example.com/authorize",
+		TokenURL:    "https://idp.example.com/token",
+		UserInfoURL: "https://idp.example.com/userinfo",
+		JWKSURL:     "https://idp.example.com/jwks",
+	}, http.DefaultClient)
+
+	if _, err := p.AuthCodeURL(context.Background(), "state", "verifier", "nonce"); err == nil {
+		t.Fatal("AuthCodeURL succeeded without an issuer")
+	}
+}
+
+func TestExchange(t *testing.T) {
+	srv := oauthtest.NewServer(t, testUser)
+	p := newProvider(srv)
+
+	profile, err := signIn(t, srv, p)
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+
+	want := oauth.Profile{
+		Subject:       testUser.Subject,
+		Email:         testUser.Email,
+		EmailVerified: true,
+		Username:      testUser.Username,
+	}
+	if *profile != want {
+		t.Errorf("profile = %+v, want %+v", *profile, want)
+	}
+}
+
+func TestExchangeWrongVerifier(t *testing.T) {
+	srv := oauthtest.NewServer(t, testUser)
+	p := newProvider(srv)
+
+	verifier, _ := oauth.NewVerifier()
+	authURL, err := p.AuthCodeURL(context.Background(), "some-state", verifier, "some-nonce")
+	if err != nil {
+		t.Fatalf("AuthCodeURL: %v", err)
+	}
+	code := authorize(t, srv, authURL)
+
+	other, _ := oauth.NewVerifier()
+	if _, err := p.Exchange(context.Background(), code, other, "some-nonce"); !errors.Is(err, oauth.ErrDenied) {
+		t.Fatalf("Exchange error = %v, want %v", err, oauth.ErrDenied)
+	}
+}
+
+func TestExchangeUsedCode(t *testing.T) {
+	srv := oauthtest.NewServer(t, testUser)
+	p := newProvider(srv)
+
+	verifier, _ := oauth.NewVerifier()
+	authURL, err := p.AuthCodeURL(context.Background(), "some-state", verifier, "some-nonce")
+	if err != nil {
+		t.Fatalf("AuthCodeURL: %v", err)
+	}
+	code := authorize(t, srv, authURL)
+
+	if _, err := p.Exchange(context.Background(), code, verifier, "some-nonce"); err != nil {
+		t.Fatalf("first Exchange: %v", err)
+	}
+	if _, err := p.Exchange(context.Background(), code, verifier, "some-nonce"); !errors.Is(err, oauth.ErrDenied) {
+		t.Fatalf("second Exchange error = %v, want %v", err, oauth.ErrDenied)
+	}
+}
+
+func TestExchangeRejectsBadIDTokens(t *testing.T) {
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		edit   func(jwt.MapClaims)
+		sign   func(*oauthtest.Server, jwt.MapClaims) (string, error)
+		denied bool
+	}{
+		{
+			name:   "other audience",
+			edit:   func(c jwt.MapClaims) { c["aud"] = "someone-else" },
+			denied: true,
+		},
+		{
+			name:   "other issuer",
+			edit:   func(c jwt.MapClaims) { c["iss"] = "https://evil.example.com" },
+			denied: true,
+		},
+		{
+			name:   "expired",
+			edit:   func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Hour).Unix() },
+			denied: true,
+		},
+		{
+			name:   "no expiry",
+			edit:   func(c jwt.MapClaims) { delete(c, "exp") },
+			denied: true,
+		},
+		{
+			name:   "other nonce",
+			edit:   func(c jwt.MapClaims) { c["nonce"] = "replayed" },
+			denied: true,
+		},
+		{
+			name:   "no nonce",
+			edit:   func(c jwt.MapClaims) { delete(c, "nonce") },
+			denied: true,
+		},
+		{
+			name:   "other subject than userinfo",
+			edit:   func(c jwt.MapClaims) { c["sub"] = "user-456" },
+			denied: true,
+		},
+		{
+			name: "signed by another key",
+			sign: func(srv *oauthtest.Server, c jwt.MapClaims) (string, error) {
+				token := jwt.NewWithClaims(jwt.SigningMethodRS256, c)
+				token.Header["kid"] = srv.KeyID()
+				return token.SignedString(otherKey)
+			},
+			denied: true,
+		},
+		{
+			name: "unsigned",
+			sign: func(srv *oauthtest.Server, c jwt.MapClaims) (string, error) {
+				token := jwt.NewWithClaims(jwt.SigningMethodNone, c)
+				token.Header["kid"] = srv.KeyID()
+				return token.SignedString(jwt.UnsafeAllowNoneSignatureType)
+			},
+			denied: true,
+		},
+		{
+			name: "missing",
+			sign: func(*oauthtest.Server, jwt.MapClaims) (string, error) { return "", nil },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := oauthtest.NewServer(t, testUser)
+			srv.EditClaims = tt.edit
+			if tt.sign != nil {
+				srv.Sign = func(c jwt.MapClaims) (string, error) { return tt.sign(srv, c) }
+			}
+
+			_, err := signIn(t, srv, newProvider(srv))
+			if err == nil {
+				t.Fatal("Exchange accepted the id token")
+			}
+			if tt.denied && !errors.Is(err, oauth.ErrDenied) {
+				t.Errorf("Exchange error = %v, want %v", err, oauth.ErrDenied)
+			}
+		})
+	}
+}
+
+func TestExchangeRefetchesRotatedKeys(t *testing.T) {
+	srv := oauthtest.NewServer(t, testUser)
+	p := newProvider(srv)
+
+	for i := 0; i < 2; i++ {
+		if _, err := signIn(t, srv, p); err != nil {
+			t.Fatalf("sign-in %d: %v", i+1, err)
+		}
+	}
+	if got := srv.KeyFetches(); got != 1 {
+		t.Fatalf("keys fetched %d times for one key, want 1", got)
+	}
+
+	if err := srv.RotateKey(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := signIn(t, srv, p); err != nil {
+		t.Fatalf("sign-in after rotation: %v", err)
+	}
+	if got := srv.KeyFetches(); got != 2 {
+		t.Errorf("keys fetched %d times after rotation, want 2", got)
+	}
+}
+
+func TestGitHubAuthCodeURLHasNoNonce(t *testing.T) {
+	p := oauth.NewGitHub(oauth.Config{ClientID: "github-client", RedirectURL: redirectURL}, http.DefaultClient)
+
+	authURL, err := p.AuthCodeURL(context.Background(), "some-state", "the-verifier", "some-nonce")
+	if err != nil {
+		t.Fatalf("AuthCodeURL: %v", err)
+	}
+	if strings.Contains(authURL, "nonce=") {
+		t.Errorf("GitHub auth URL %s has a nonce", authURL)
+	}
+}
@@ -0,0 +1,340 @@
+// Package oauthtest runs a fake OpenID Connect provider for tests. It
+// serves discovery, signing keys, the token endpoint and userinfo, and
+// approves sign-ins as whichever user is set on it.
+package oauthtest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	ClientID     = "test-client"
+	ClientSecret = "test-secret"
+)
+
+// User is the account signed in at the provider.
+type User struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Username      string
+}
+
+type grant struct {
+	clientID    string
+	redirectURI string
+	challenge   string
+	nonce       string
+	user        User
+}
+
+type Server struct {
+	*httptest.Server
+
+	// EditClaims, when set, changes the ID token's claims before they are
+	// signed.
+	EditClaims func(jwt.MapClaims)
+	// Sign, when set, replaces how ID tokens are signed.
+	Sign func(jwt.MapClaims) (string, error)
+
+	mu     sync.Mutex
+	user   User
+	key    *rsa.PrivateKey
+	kid    string
+	codes  map[string]grant
+	tokens map[string]User
+	// keyFetches counts requests for the signing keys.
+	keyFetches int
+}
+
+// NewServer starts a provider that is shut down when the test ends.
+func NewServer(t testing.TB, user User) *Server {
+	t.Helper()
+
+	s := &Server{
+		user:   user,
+		codes:  make(map[string]grant),
+		tokens: make(map[string]User),
+	}
+	if err := s.RotateKey(); err != nil {
+		t.Fatalf("generating signing key: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", s.discovery)
+	mux.HandleFunc("GET /authorize", s.authorizeHandler)
+	mux.HandleFunc("POST /token", s.token)
+	mux.HandleFunc("GET /userinfo", s.userinfo)
+	mux.HandleFunc("GET /jwks", s.jwks)
+
+	s.Server = httptest.NewServer(mux)
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+// Issuer is the URL the provider is configured with.
+func (s *Server) Issuer() string {
+	return s.URL
+}
+
+// SetUser changes who signs in from now on.
+func (s *Server) SetUser(user User) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.user = user
+}
+
+// RotateKey replaces the signing key with a new one under a new key ID.
+func (s *Server) RotateKey() error {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.key = key
+	s.kid = fmt.Sprintf("key-%d", time.Now().UnixNano())
+	return nil
+}
+
+// KeyID is the ID of the current signing key.
+func (s *Server) KeyID() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.kid
+}
+
+// KeyFetches reports how often the signing keys have been requested.
+func (s *Server) KeyFetches() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.keyFetches
+}
+
+// Authorize plays the user approving the sign-in at authURL and returns
+// the callback URL the provider redirects them to.
+func (s *Server) Authorize(authURL string) (string, error) {
+	u, err := url.Parse(authURL)
+	if err != nil {
+		return "", err
+	}
+	if !strings.HasPrefix(authURL, s.URL+"/authorize") {
+		return "", fmt.Errorf("%s is not this provider's authorization endpoint", u.Redacted())
+	}
+
+	q := u.Query()
+	switch {
+	case q.Get("response_type") != "code":
+		return "", errors.New("response_type must be code")
+	case q.Get("client_id") != ClientID:
+		return "", fmt.Errorf("unknown client %q", q.Get("client_id"))
+	case q.Get("redirect_uri") == "":
+		return "", errors.New("redirect_uri is missing")
+	case q.Get("code_challenge_method") != "S256" || q.Get("code_challenge") == "":
+		return "", errors.New("an S256 code challenge is required")
+	}
+
+	code := randomString()
+
+	s.mu.Lock()
+	s.codes[code] = grant{
+		clientID:    q.Get("client_id"),
+		redirectURI: q.Get("redirect_uri"),
+		challenge:   q.Get("code_challenge"),
+		nonce:       q.Get("nonce"),
+		user:        s.user,
+	}
+	s.mu.Unlock()
+
+	callback, err := url.Parse(q.Get("redirect_uri"))
+	if err != nil {
+		return "", err
+	}
+	params := callback.Query()
+	params.Set("code", code)
+	params.Set("state", q.Get("state"))
+	callback.RawQuery = params.Encode()
+
+	return callback.String(), nil
+}
+
+func (s *Server) authorizeHandler(w http.ResponseWriter, r *http.Request) {
+	callback, err := s.Authorize(s.URL + r.URL.RequestURI())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	http.Redirect(w, r, callback, http.StatusFound)
+}
+
+func (s *Server) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{
+		"issuer":                 s.URL,
+		"authorization_endpoint": s.URL + "/authorize",
+		"token_endpoint":         s.URL + "/token",
+		"userinfo_endpoint":      s.URL + "/userinfo",
+		"jwks_uri":               s.URL + "/jwks",
+	})
+}
+
+func (s *Server) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		tokenError(w, "invalid_request", err.Error())
+		return
+	}
+
+	if r.PostForm.Get("client_id") != ClientID || r.PostForm.Get("client_secret") != ClientSecret {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+	if r.PostForm.Get("grant_type") != "authorization_code" {
+		tokenError(w, "unsupported_grant_type", "")
+		return
+	}
+
+	s.mu.Lock()
+	code := r.PostForm.Get("code")
+	g, ok := s.codes[code]
+	// Codes can only be used once, whether or not the exchange works.
+	delete(s.codes, code)
+	s.mu.Unlock()
+
+	challenge := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	switch {
+	case !ok:
+		tokenError(w, "invalid_grant", "unknown or used code")
+		return
+	case g.redirectURI != r.PostForm.Get("redirect_uri"):
+		tokenError(w, "invalid_grant", "redirect_uri does not match")
+		return
+	case base64.RawURLEncoding.EncodeToString(challenge[:]) != g.challenge:
+		tokenError(w, "invalid_grant", "code_verifier does not match the challenge")
+		return
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss": s.URL,
+		"sub": g.user.Subject,
+		"aud": g.clientID,
+		"iat": now.Unix(),
+		"exp": now.Add(time.Hour).Unix(),
+	}
+	if g.nonce != "" {
+		claims["nonce"] = g.nonce
+	}
+	if s.EditClaims != nil {
+		s.EditClaims(claims)
+	}
+
+	sign := s.sign
+	if s.Sign != nil {
+		sign = s.Sign
+	}
+	idToken, err := sign(claims)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	accessToken := randomString()
+	s.mu.Lock()
+	s.tokens[accessToken] = g.user
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"access_token": accessToken,
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     idToken,
+	})
+}
+
+func (s *Server) sign(claims jwt.MapClaims) (string, error) {
+	s.mu.Lock()
+	key, kid := s.key, s.kid
+	s.mu.Unlock()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = kid
+	return token.SignedString(key)
+}
+
+func (s *Server) userinfo(w http.ResponseWriter, r *http.Request) {
+	accessToken, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+
+	s.mu.Lock()
+	user, known := s.tokens[accessToken]
+	s.mu.Unlock()
+
+	if !ok || !known {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"sub":                user.Subject,
+		"email":              user.Email,
+		"email_verified":     user.EmailVerified,
+		"preferred_username": user.Username,
+	})
+}
+
+func (s *Server) jwks(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.keyFetches++
+	key, kid := s.key, s.kid
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"use": "sig",
+			"alg": "RS256",
+			"kid": kid,
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}},
+	})
+}
+
+func tokenError(w http.ResponseWriter, code, description string) {
+	writeJSON(w, http.StatusBadRequest, map[string]string{
+		"error":             code,
+		"error_description": description,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func randomString() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
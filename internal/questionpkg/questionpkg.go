@@ -0,0 +1,435 @@
+// Package questionpkg reads and writes questions in a portable format so
+// they can be authored offline and kept under version control.
+//
+// A package is a directory, or a zip of one, laid out as:
+//
+//	question.json   title, difficulty, rating, tags, limits, checker, samples
+//	statement.md    description, then "## Input" and "## Output" sections
+//	tests/1.in      numbered test inputs, starting at 1
+//	tests/1.out     expected output for the test with the same number
+//	checker.py      special checker source, if the checker needs one
+//	solution.py     reference solution, used only for validation
+//
+// Test numbers may be zero padded. The tests listed in "samples" are shown
+// to players and the first of them is the statement's example.
+package questionpkg
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"ws_practice_1/internal/judge"
+	"ws_practice_1/internal/store"
+)
+
+const (
+	ManifestFile  = "question.json"
+	StatementFile = "statement.md"
+	TestsDir      = "tests"
+)
+
+// Package is a question together with everything needed to check it.
+type Package struct {
+	// Question holds the statement, settings and test cases. Samples are
+	// the test cases that are not hidden.
+	Question store.DSAQuestion
+	// Solution is the reference solution. It is never stored with the
+	// question and may be nil.
+	Solution *Source
+}
+
+type Source struct {
+	Code       string
+	LanguageID int
+}
+
+type manifest struct {
+	Title         string           `json:"title"`
+	Difficulty    string           `json:"difficulty"`
+	Rating        int              `json:"rating,omitempty"`
+	Tags          []string         `json:"tags"`
+	TimeLimitMs   int              `json:"time_limit_ms,omitempty"`
+	MemoryLimitKB int              `json:"memory_limit_kb,omitempty"`
+	Checker       checkerManifest  `json:"checker"`
+	Samples       []int            `json:"samples"`
+	Solution      *sourceReference `json:"solution,omitempty"`
+}
+
+type checkerManifest struct {
+	Mode       string  `json:"mode"`
+	Tolerance  float64 `json:"tolerance,omitempty"`
+	Source     string  `json:"source,omitempty"`
+	LanguageID int     `json:"language_id,omitempty"`
+}
+
+type sourceReference struct {
+	Source     string `json:"source"`
+	LanguageID int    `json:"language_id"`
+}
+
+// Load reads the package at path, which is either a directory or a file
+// ending in .zip.
+func Load(path string) (*Package, error) {
+	if strings.EqualFold(filepath.Ext(path), ".zip") {
+		r, err := zip.OpenReader(path)
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+
+		return Read(&r.Reader)
+	}
+
+	return Read(os.DirFS(path))
+}
+
+// LoadAll loads every package directory and zip directly inside dir, in
+// name order.
+func LoadAll(dir string) ([]*Package, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var pkgs []*Package
+	for _, entry := range entries {
+		if !entry.IsDir() && !strings.EqualFold(filepath.Ext(entry.Name()), ".zip") {
+			continue
+		}
+
+		p, err := Load(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", entry.Name(), err)
+		}
+		pkgs = append(pkgs, p)
+	}
+
+	return pkgs, nil
+}
+
+// Read reads a package from fsys. Zips that wrap the package in a single
+// top-level directory are accepted too.
+func Read(fsys fs.FS) (*Package, error) {
+	fsys, err := packageRoot(fsys)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := fs.ReadFile(fsys, ManifestFile)
+	if err != nil {
+		return nil, err
+	}
+
+	var m manifest
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&m); err != nil {
+		return nil, fmt.Errorf("%s: %w", ManifestFile, err)
+	}
+
+	statement, err := fs.ReadFile(fsys, StatementFile)
+	if err != nil {
+		return nil, err
+	}
+
+	description, inputFormat, outputFormat, err := parseStatement(string(statement))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", StatementFile, err)
+	}
+
+	tests, err := readTests(fsys)
+	if err != nil {
+		return nil, err
+	}
+
+	q := store.DSAQuestion{
+		Title:         m.Title,
+		Description:   description,
+		InputFormat:   inputFormat,
+		OutputFormat:  outputFormat,
+		Difficulty:    m.Difficulty,
+		Rating:        m.Rating,
+		Tags:          m.Tags,
+		TimeLimitMs:   m.TimeLimitMs,
+		MemoryLimitKB: m.MemoryLimitKB,
+		Checker:       m.Checker.Mode,
+		Tolerance:     m.Checker.Tolerance,
+		CheckerLangID: m.Checker.LanguageID,
+		TestCases:     tests,
+	}
+
+	for i := range q.TestCases {
+		q.TestCases[i].IsHidden = !slices.Contains(m.Samples, i+1)
+	}
+	for _, n := range m.Samples {
+		if n < 1 || n > len(tests) {
+			return nil, fmt.Errorf("%s: sample %d is not a test", ManifestFile, n)
+		}
+	}
+	if len(m.Samples) > 0 {
+		example := tests[m.Samples[0]-1]
+		q.ExampleInput = example.Input
+		q.ExampleOutput = example.ExpectedOutput
+	}
+
+	if m.Checker.Source != "" {
+		src, err := fs.ReadFile(fsys, m.Checker.Source)
+		if err != nil {
+			return nil, err
+		}
+		q.CheckerSource = string(src)
+	}
+
+	p := &Package{Question: q}
+
+	if m.Solution != nil {
+		src, err := fs.ReadFile(fsys, m.Solution.Source)
+		if err != nil {
+			return nil, err
+		}
+		p.Solution = &Source{Code: string(src), LanguageID: m.Solution.LanguageID}
+	}
+
+	return p, nil
+}
+
+func packageRoot(fsys fs.FS) (fs.FS, error) {
+	if _, err := fs.Stat(fsys, ManifestFile); err == nil {
+		return fsys, nil
+	}
+
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 1 && entries[0].IsDir() {
+		return fs.Sub(fsys, entries[0].Name())
+	}
+
+	return nil, fmt.Errorf("%s not found", ManifestFile)
+}
+
+// parseStatement splits the statement on its "## Input" and "## Output"
+// headings.
+func parseStatement(s string) (description, inputFormat, outputFormat string, err error) {
+	var sections [3][]string
+	current := 0
+
+	for _, line := range strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n") {
+		switch strings.ToLower(strings.TrimSpace(line)) {
+		case "## input":
+			if current != 0 {
+				return "", "", "", errors.New("sections must be description, input, output")
+			}
+			current = 1
+			continue
+		case "## output":
+			if current != 1 {
+				return "", "", "", errors.New("sections must be description, input, output")
+			}
+			current = 2
+			continue
+		}
+		sections[current] = append(sections[current], line)
+	}
+
+	if current != 2 {
+		return "", "", "", errors.New(`missing "## Input" or "## Output" section`)
+	}
+
+	text := func(lines []string) string {
+		return strings.TrimSpace(strings.Join(lines, "\n"))
+	}
+
+	return text(sections[0]), text(sections[1]), text(sections[2]), nil
+}
+
+func readTests(fsys fs.FS) ([]store.TestCase, error) {
+	entries, err := fs.ReadDir(fsys, TestsDir)
+	if err != nil {
+		return nil, err
+	}
+
+	inputs := map[int]string{}
+	outputs := map[int]string{}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || strings.HasPrefix(name, ".") {
+			continue
+		}
+
+		ext := path.Ext(name)
+		n, err := strconv.Atoi(strings.TrimSuffix(name, ext))
+		if err != nil || n < 1 || (ext != ".in" && ext != ".out") {
+			return nil, fmt.Errorf("%s/%s: test files must be named <number>.in or <number>.out", TestsDir, name)
+		}
+
+		data, err := fs.ReadFile(fsys, path.Join(TestsDir, name))
+		if err != nil {
+			return nil, err
+		}
+
+		files := inputs
+		if ext == ".out" {
+			files = outputs
+		}
+		if _, ok := files[n]; ok {
+			return nil, fmt.Errorf("%s: test %d%s appears more than once", TestsDir, n, ext)
+		}
+		files[n] = string(data)
+	}
+
+	tests := make([]store.TestCase, 0, len(inputs))
+	for n := 1; n <= len(inputs); n++ {
+		input, ok := inputs[n]
+		if !ok {
+			return nil, fmt.Errorf("%s: tests must be numbered from 1 without gaps, missing %d.in", TestsDir, n)
+		}
+		output, ok := outputs[n]
+		if !ok {
+			return nil, fmt.Errorf("%s: missing %d.out", TestsDir, n)
+		}
+		tests = append(tests, store.TestCase{Input: input, ExpectedOutput: output})
+	}
+	if len(outputs) > len(inputs) {
+		return nil, fmt.Errorf("%s: every .out file needs a matching .in file", TestsDir)
+	}
+
+	return tests, nil
+}
+
+// Write saves the package to path, as a zip if path ends in .zip and as a
+// directory otherwise.
+func Write(path string, p *Package) error {
+	files, err := p.files()
+	if err != nil {
+		return err
+	}
+
+	if strings.EqualFold(filepath.Ext(path), ".zip") {
+		return writeZip(path, files)
+	}
+
+	for _, name := range sortedNames(files) {
+		target := filepath.Join(path, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(target, files[name], 0o644); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func writeZip(path string, files map[string][]byte) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	zw := zip.NewWriter(f)
+	for _, name := range sortedNames(files) {
+		w, err := zw.Create(name)
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(files[name]); err != nil {
+			return err
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		return err
+	}
+
+	return f.Close()
+}
+
+func (p *Package) files() (map[string][]byte, error) {
+	q := p.Question
+	files := map[string][]byte{}
+
+	m := manifest{
+		Title:         q.Title,
+		Difficulty:    q.Difficulty,
+		Rating:        q.Rating,
+		Tags:          q.Tags,
+		TimeLimitMs:   q.TimeLimitMs,
+		MemoryLimitKB: q.MemoryLimitKB,
+		Checker: checkerManifest{
+			Mode:       q.Checker,
+			Tolerance:  q.Tolerance,
+			LanguageID: q.CheckerLangID,
+		},
+		Samples: []int{},
+	}
+	if m.Tags == nil {
+		m.Tags = []string{}
+	}
+
+	width := len(strconv.Itoa(len(q.TestCases)))
+	for i, tc := range q.TestCases {
+		name := fmt.Sprintf("%s/%0*d", TestsDir, width, i+1)
+		files[name+".in"] = []byte(tc.Input)
+		files[name+".out"] = []byte(tc.ExpectedOutput)
+		if !tc.IsHidden {
+			m.Samples = append(m.Samples, i+1)
+		}
+	}
+
+	if q.CheckerSource != "" {
+		m.Checker.Source = sourceFileName("checker", q.CheckerLangID)
+		files[m.Checker.Source] = []byte(q.CheckerSource)
+	}
+
+	if p.Solution != nil {
+		m.Solution = &sourceReference{
+			Source:     sourceFileName("solution", p.Solution.LanguageID),
+			LanguageID: p.Solution.LanguageID,
+		}
+		files[m.Solution.Source] = []byte(p.Solution.Code)
+	}
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	files[ManifestFile] = append(data, '\n')
+
+	statement := fmt.Sprintf("%s\n\n## Input\n\n%s\n\n## Output\n\n%s\n", q.Description, q.InputFormat, q.OutputFormat)
+	files[StatementFile] = []byte(statement)
+
+	return files, nil
+}
+
+// sourceFileName names a source file after its language's usual extension.
+func sourceFileName(base string, languageID int) string {
+	ext := ".txt"
+	if lang, ok := judge.DefaultLanguages[languageID]; ok {
+		ext = path.Ext(lang.SourceFile)
+	}
+	return base + ext
+}
+
+func sortedNames(files map[string][]byte) []string {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
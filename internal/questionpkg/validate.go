@@ -0,0 +1,165 @@
+package questionpkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"ws_practice_1/internal/judge"
+	"ws_practice_1/internal/store"
+)
+
+// Validate checks the package without running anything and reports every
+// problem it finds. The limits match what the admin API accepts.
+func (p *Package) Validate() error {
+	q := p.Question
+	var errs []error
+	fail := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	if strings.TrimSpace(q.Title) == "" {
+		fail("title is required")
+	} else if len(q.Title) > 200 {
+		fail("title is longer than 200 characters")
+	}
+	if q.Description == "" || q.InputFormat == "" || q.OutputFormat == "" {
+		fail("statement needs a description, an input and an output section")
+	}
+
+	switch q.Difficulty {
+	case store.DifficultyEasy, store.DifficultyMedium, store.DifficultyHard:
+	default:
+		fail("difficulty must be easy, medium or hard, got %q", q.Difficulty)
+	}
+	if q.Rating != 0 && (q.Rating < 100 || q.Rating > 4000) {
+		fail("rating must be between 100 and 4000")
+	}
+
+	if len(q.Tags) > 10 {
+		fail("at most 10 tags are allowed")
+	}
+	for _, tag := range q.Tags {
+		if tag == "" || len(tag) > 50 {
+			fail("tags must be 1 to 50 characters, got %q", tag)
+		}
+	}
+
+	if q.TimeLimitMs != 0 && (q.TimeLimitMs < 100 || q.TimeLimitMs > 20000) {
+		fail("time_limit_ms must be between 100 and 20000")
+	}
+	if q.MemoryLimitKB != 0 && (q.MemoryLimitKB < 16384 || q.MemoryLimitKB > 1048576) {
+		fail("memory_limit_kb must be between 16384 and 1048576")
+	}
+
+	if q.Checker != "" && !judge.ValidCheckerMode(q.Checker) {
+		fail("unknown checker mode %q", q.Checker)
+	}
+	if q.Checker == judge.CheckerSpecial && (q.CheckerSource == "" || q.CheckerLangID == 0) {
+		fail("special checker needs a source and a language_id")
+	}
+	if q.Tolerance < 0 {
+		fail("checker tolerance must not be negative")
+	}
+
+	if len(q.TestCases) == 0 {
+		fail("at least one test is required")
+	} else if len(q.TestCases) > 100 {
+		fail("at most 100 tests are allowed")
+	}
+	samples := 0
+	for _, tc := range q.TestCases {
+		if !tc.IsHidden {
+			samples++
+		}
+	}
+	if samples == 0 {
+		fail("at least one test must be a sample")
+	}
+
+	if p.Solution != nil && (p.Solution.Code == "" || p.Solution.LanguageID == 0) {
+		fail("solution needs a source and a language_id")
+	}
+
+	return errors.Join(errs...)
+}
+
+// TestResult is how the reference solution did on one test.
+type TestResult struct {
+	Index   int
+	Verdict string
+	Time    float64
+	Memory  int
+	Stderr  string
+}
+
+// Verify runs the reference solution against every test with the
+// question's limits and checker, falling back to the limits the question
+// would get once stored. It returns an error if the package has no
+// solution, the judge fails, or any test is not accepted; the results
+// gathered so far are returned either way.
+func (p *Package) Verify(ctx context.Context, j judge.Judge) ([]TestResult, error) {
+	if p.Solution == nil {
+		return nil, errors.New("package has no reference solution")
+	}
+
+	q := p.Question
+	if q.TimeLimitMs == 0 {
+		q.TimeLimitMs = store.DefaultTimeLimitMs
+	}
+	if q.MemoryLimitKB == 0 {
+		q.MemoryLimitKB = store.DefaultMemoryLimitKB
+	}
+	checker := judge.Checker{
+		Mode:       q.Checker,
+		Tolerance:  q.Tolerance,
+		Source:     q.CheckerSource,
+		LanguageID: q.CheckerLangID,
+	}
+
+	var results []TestResult
+	failed := 0
+
+	for i, tc := range q.TestCases {
+		res, err := j.Execute(ctx, judge.Submission{
+			SourceCode:    p.Solution.Code,
+			LanguageID:    p.Solution.LanguageID,
+			Stdin:         tc.Input,
+			TimeLimit:     time.Duration(q.TimeLimitMs) * time.Millisecond,
+			MemoryLimitKB: q.MemoryLimitKB,
+		})
+		if err != nil {
+			return results, fmt.Errorf("test %d: %w", i+1, err)
+		}
+
+		result := TestResult{
+			Index:   i + 1,
+			Verdict: judge.VerdictFor(res, q.MemoryLimitKB),
+			Time:    res.Time,
+			Memory:  res.Memory,
+			Stderr:  res.Stderr + res.CompileOutput,
+		}
+
+		if result.Verdict == judge.VerdictAccepted {
+			ok, err := checker.Check(ctx, j, tc.Input, tc.ExpectedOutput, res.Stdout)
+			if err != nil {
+				return results, fmt.Errorf("test %d: %w", i+1, err)
+			}
+			if !ok {
+				result.Verdict = judge.VerdictWrongAnswer
+			}
+		}
+
+		if result.Verdict != judge.VerdictAccepted {
+			failed++
+		}
+		results = append(results, result)
+	}
+
+	if failed > 0 {
+		return results, fmt.Errorf("reference solution failed %d of %d tests", failed, len(results))
+	}
+
+	return results, nil
+}
@@ -0,0 +1,109 @@
+package rating
+
+import "math"
+
+const (
+	DefaultRating     = 1500.0
+	DefaultDeviation  = 350.0
+	DefaultVolatility = 0.06
+
+	// tau constrains how quickly volatility can change; Glickman suggests
+	// values between 0.3 and 1.2.
+	tau     = 0.5
+	scale   = 173.7178
+	epsilon = 0.000001
+)
+
+const (
+	Loss = 0.0
+	Draw = 0.5
+	Win  = 1.0
+)
+
+// Rating is a Glicko-2 rating on the familiar Glicko scale (1500 ± 350).
+type Rating struct {
+	Rating     float64
+	Deviation  float64
+	Volatility float64
+}
+
+func Default() Rating {
+	return Rating{Rating: DefaultRating, Deviation: DefaultDeviation, Volatility: DefaultVolatility}
+}
+
+// Update returns the player's new rating after a single game against the
+// opponent, treating the game as its own rating period. score is Win, Draw
+// or Loss from the player's point of view.
+func Update(player, opponent Rating, score float64) Rating {
+	mu := (player.Rating - DefaultRating) / scale
+	phi := player.Deviation / scale
+	muJ := (opponent.Rating - DefaultRating) / scale
+	phiJ := opponent.Deviation / scale
+
+	g := gFactor(phiJ)
+	e := expected(mu, muJ, g)
+	v := 1 / (g * g * e * (1 - e))
+	delta := v * g * (score - e)
+
+	sigma := newVolatility(phi, v, delta, player.Volatility)
+
+	phiStar := math.Sqrt(phi*phi + sigma*sigma)
+	newPhi := 1 / math.Sqrt(1/(phiStar*phiStar)+1/v)
+	newMu := mu + newPhi*newPhi*g*(score-e)
+
+	return Rating{
+		Rating:     newMu*scale + DefaultRating,
+		Deviation:  math.Min(newPhi*scale, DefaultDeviation),
+		Volatility: sigma,
+	}
+}
+
+// Game rates a finished game between a and b, where score is a's result.
+func Game(a, b Rating, score float64) (Rating, Rating) {
+	return Update(a, b, score), Update(b, a, 1-score)
+}
+
+func gFactor(phi float64) float64 {
+	return 1 / math.Sqrt(1+3*phi*phi/(math.Pi*math.Pi))
+}
+
+func expected(mu, muJ, g float64) float64 {
+	return 1 / (1 + math.Exp(-g*(mu-muJ)))
+}
+
+// newVolatility solves for the new volatility with the Illinois algorithm
+// from step 5 of Glickman's paper.
+func newVolatility(phi, v, delta, sigma float64) float64 {
+	a := math.Log(sigma * sigma)
+	f := func(x float64) float64 {
+		ex := math.Exp(x)
+		d := phi*phi + v + ex
+		return ex*(delta*delta-phi*phi-v-ex)/(2*d*d) - (x-a)/(tau*tau)
+	}
+
+	A := a
+	var B float64
+	if delta*delta > phi*phi+v {
+		B = math.Log(delta*delta - phi*phi - v)
+	} else {
+		k := 1.0
+		for f(a-k*tau) < 0 {
+			k++
+		}
+		B = a - k*tau
+	}
+
+	fA, fB := f(A), f(B)
+	for math.Abs(B-A) > epsilon {
+		C := A + (A-B)*fA/(fB-fA)
+		fC := f(C)
+		if fC*fB <= 0 {
+			A, fA = B, fB
+		} else {
+			fA /= 2
+		}
+		B, fB = C, fC
+	}
+
+	return math.Exp(A / 2)
+}
@@ -10,21 +10,56 @@ type MatchStore struct {
 	db *sql.DB
 }
 
-func (m *MatchStore) Create(ctx context.Context, match *Match) error {
+func createMatch(ctx context.Context, tx *sql.Tx, match *Match) error {
+	query := `
+		INSERT INTO matches (player1_id, player2_id, winner_id, question_id, ended_at)
+        VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	return tx.QueryRowContext(
+		ctx,
+		query,
+		match.Player1ID,
+		match.Player2ID,
+		match.WinnerID,
+		match.QuestionID,
+		match.EndedAt,
+	).Scan(&match.ID, &match.CreatedAt)
+}
+
+// finishMatch sets the result of a match that was created when it started.
+func finishMatch(ctx context.Context, tx *sql.Tx, match *Match) error {
 	query := `
-		INSERT INTO matches (player1_id, player2_id, winner_id, question_id)
-        VALUES ($1, $2, $3, $4)
+		UPDATE matches SET winner_id = $1, ended_at = NOW()
+		WHERE id = $2 AND ended_at IS NULL
+		RETURNING ended_at
 	`
 
-	_, err := m.db.ExecContext(ctx, query, match.Player1ID, match.Player2ID, match.WinnerID, match.QuestionID)
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	err := tx.QueryRowContext(ctx, query, match.WinnerID, match.ID).Scan(&match.EndedAt)
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
 	return err
 }
 
+func (m *MatchStore) Create(ctx context.Context, match *Match) error {
+	return withTx(m.db, ctx, func(tx *sql.Tx) error {
+		return createMatch(ctx, tx, match)
+	})
+}
+
 func (m *MatchStore) GetMatchesWonByUser(ctx context.Context, userID int64) (int, error) {
 	query := `
 		SELECT COUNT(*) 
 		FROM matches 
-		WHERE winner_id = $1
+		WHERE winner_id = $1 AND ended_at IS NOT NULL
 	`
 
 	var count int
@@ -40,7 +75,7 @@ func (m *MatchStore) GetMatchesPlayedByUser(ctx context.Context, userID int64) (
 	query := `
 		SELECT COUNT(*) 
 		FROM matches 
-		WHERE player1_id = $1 OR player2_id = $1
+		WHERE (player1_id = $1 OR player2_id = $1) AND ended_at IS NOT NULL
 	`
 
 	var count int
@@ -54,7 +89,7 @@ func (m *MatchStore) GetMatchesPlayedByUser(ctx context.Context, userID int64) (
 
 func (m *MatchStore) GetTotalMatchesPlayed(ctx context.Context) (int, error) {
 	query := `
-		SELECT COUNT(*) FROM matches
+		SELECT COUNT(*) FROM matches WHERE ended_at IS NOT NULL
 	`
 
 	var count int
@@ -3,30 +3,216 @@ package store
 import "time"
 
 type User struct {
-	ID        int64  `json:"id"`
-	Email     string `json:"email"`
-	Password  []byte `json:"-"`
-	Username  string `json:"username"`
-	Points    int    `json:"points"`
-	CreatedAt string `json:"created_at"`
-	UpdatedAt string `json:"updated_at"`
+	ID               int64      `json:"id"`
+	Email            string     `json:"email"`
+	Password         []byte     `json:"-"`
+	Username         string     `json:"username"`
+	Points           int        `json:"points"`
+	Rating           float64    `json:"rating"`
+	RatingDeviation  float64    `json:"rating_deviation"`
+	RatingVolatility float64    `json:"-"`
+	Role             string     `json:"role"`
+	EmailVerifiedAt  *time.Time `json:"email_verified_at"`
+	CreatedAt        string     `json:"created_at"`
+	UpdatedAt        string     `json:"updated_at"`
 }
 
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+// roleRanks orders roles so that each one includes the ones below it.
+var roleRanks = map[string]int{
+	RoleUser:      1,
+	RoleModerator: 2,
+	RoleAdmin:     3,
+}
+
+func ValidRole(role string) bool {
+	_, ok := roleRanks[role]
+	return ok
+}
+
+func (u *User) EmailVerified() bool {
+	return u.EmailVerifiedAt != nil
+}
+
+// HasRole reports whether the user's role is role or above it.
+func (u *User) HasRole(role string) bool {
+	return roleRanks[u.Role] >= roleRanks[role]
+}
+
+// Session is one signed-in device. Access tokens carry the session ID so
+// revoking the session signs the device out before its token expires.
+type Session struct {
+	ID         int64      `json:"id"`
+	UserID     int64      `json:"user_id"`
+	UserAgent  string     `json:"user_agent"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	LastUsedAt time.Time  `json:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// Active reports whether the session can still be used at now.
+func (s *Session) Active(now time.Time) bool {
+	return s.RevokedAt == nil && now.Before(s.ExpiresAt)
+}
+
+const (
+	TokenPurposeVerifyEmail   = "verify_email"
+	TokenPurposeResetPassword = "reset_password"
+)
+
+// UserToken is a single-use token sent by email to prove the user controls
+// the address. Only its hash is stored.
+type UserToken struct {
+	UserID    int64     `json:"user_id"`
+	Purpose   string    `json:"purpose"`
+	ExpiresAt time.Time `json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// UserIdentity links a user to their account at an OAuth provider. A user
+// has at most one identity per provider.
+type UserIdentity struct {
+	ID        int64     `json:"id"`
+	UserID    int64     `json:"user_id"`
+	Provider  string    `json:"provider"`
+	Subject   string    `json:"-"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// DSAQuestion is one version of a question. Editing a question adds a new
+// version with the same LineageID, so matches keep the text they were
+// played with.
 type DSAQuestion struct {
-	ID            int64  `json:"id"`
-	Title         string `json:"title"`
-	Description   string `json:"description"`
-	InputFormat   string `json:"input_format"`
-	OutputFormat  string `json:"output_format"`
-	ExampleInput  string `json:"example_input"`
-	ExampleOutput string `json:"example_output"`
+	ID            int64      `json:"id"`
+	LineageID     int64      `json:"lineage_id"`
+	Version       int        `json:"version"`
+	RetiredAt     *time.Time `json:"retired_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	Title         string     `json:"title"`
+	Description   string     `json:"description"`
+	InputFormat   string     `json:"input_format"`
+	OutputFormat  string     `json:"output_format"`
+	ExampleInput  string     `json:"example_input"`
+	ExampleOutput string     `json:"example_output"`
+	Difficulty    string     `json:"difficulty"`
+	Rating        int        `json:"rating"`
+	Tags          []string   `json:"tags"`
+	TimeLimitMs   int        `json:"time_limit_ms"`
+	MemoryLimitKB int        `json:"memory_limit_kb"`
+	Checker       string     `json:"checker"`
+	Tolerance     float64    `json:"-"`
+	CheckerSource string     `json:"-"`
+	CheckerLangID int        `json:"-"`
+	Samples       []TestCase `json:"samples,omitempty"`
+	TestCases     []TestCase `json:"-"`
+}
+
+const (
+	DifficultyEasy   = "easy"
+	DifficultyMedium = "medium"
+	DifficultyHard   = "hard"
+)
+
+// Limits for questions that do not set their own.
+const (
+	DefaultTimeLimitMs   = 2000
+	DefaultMemoryLimitKB = 256 * 1024
+)
+
+// QuestionCriteria describes the question a match wants. Zero values mean
+// no preference.
+type QuestionCriteria struct {
+	// Rating is the rating the question should be closest to, and
+	// RatingWindow how far from it a question may be.
+	Rating       int
+	RatingWindow int
+	Difficulty   string
+	Tag          string
+	// ExcludeSeenBy skips questions any of these users has played before.
+	ExcludeSeenBy []int64
+}
+
+type QuestionFilter struct {
+	Difficulty     string
+	Tag            string
+	IncludeRetired bool
+	Limit          int
+	Offset         int
+}
+
+type TestCase struct {
+	ID             int64  `json:"id"`
+	QuestionID     int64  `json:"question_id"`
+	Input          string `json:"input"`
+	ExpectedOutput string `json:"expected_output"`
+	IsHidden       bool   `json:"is_hidden"`
+	Position       int    `json:"position"`
 }
 
 type Match struct {
 	ID         int64
 	Player1ID  int64
 	Player2ID  int64
-	WinnerID   int64
+	WinnerID   *int64
 	QuestionID int64
 	CreatedAt  time.Time
+	EndedAt    *time.Time
+}
+
+type Submission struct {
+	ID            int64     `json:"id"`
+	MatchID       int64     `json:"match_id"`
+	UserID        int64     `json:"user_id"`
+	QuestionID    int64     `json:"question_id"`
+	LanguageID    int       `json:"language_id"`
+	SourceCode    string    `json:"source_code"`
+	Verdict       string    `json:"verdict"`
+	TestsPassed   int       `json:"tests_passed"`
+	TotalTests    int       `json:"total_tests"`
+	FailedTest    int       `json:"failed_test,omitempty"`
+	CompileOutput string    `json:"compile_output,omitempty"`
+	Stdout        string    `json:"stdout"`
+	Stderr        string    `json:"stderr"`
+	RuntimeMs     int       `json:"runtime_ms"`
+	MemoryKB      int       `json:"memory_kb"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+type RatingChange struct {
+	ID               int64     `json:"id"`
+	MatchID          int64     `json:"match_id"`
+	UserID           int64     `json:"user_id"`
+	RatingBefore     float64   `json:"rating_before"`
+	RatingAfter      float64   `json:"rating_after"`
+	DeviationBefore  float64   `json:"deviation_before"`
+	DeviationAfter   float64   `json:"deviation_after"`
+	VolatilityBefore float64   `json:"volatility_before"`
+	VolatilityAfter  float64   `json:"volatility_after"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+const (
+	RoomStatusOpen      = "open"
+	RoomStatusMatched   = "matched"
+	RoomStatusCancelled = "cancelled"
+)
+
+type Room struct {
+	ID               int64     `json:"id"`
+	Code             string    `json:"code"`
+	HostID           int64     `json:"host_id"`
+	GuestID          *int64    `json:"guest_id"`
+	Difficulty       string    `json:"difficulty,omitempty"`
+	TimeLimitSeconds int       `json:"time_limit_seconds,omitempty"`
+	Rated            bool      `json:"rated"`
+	Status           string    `json:"status"`
+	CreatedAt        time.Time `json:"created_at"`
+	ExpiresAt        time.Time `json:"expires_at"`
 }
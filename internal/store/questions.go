@@ -3,76 +3,404 @@ package store
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+
+	"github.com/lib/pq"
 )
 
+// ErrNoSample means a question has neither a sample test nor an example to
+// show players.
+var ErrNoSample = errors.New("question needs a sample test or an example")
+
 type QuestionStore struct {
 	db *sql.DB
 }
 
+// pickCandidates is how many of the closest matching questions Pick
+// chooses from at random, so the same pair does not always get the same one.
+const pickCandidates = 10
+
+const questionColumns = `q.id, q.lineage_id, q.version, q.retired_at, q.created_at, q.title, q.description, q.input_format, q.output_format,
+	q.example_input, q.example_output, q.difficulty, q.rating, q.tags, q.time_limit_ms,
+	q.memory_limit_kb, q.checker, q.checker_tolerance, q.checker_source, q.checker_language_id`
+
+func scanQuestion(row interface{ Scan(...any) error }, q *DSAQuestion) error {
+	return row.Scan(
+		&q.ID,
+		&q.LineageID,
+		&q.Version,
+		&q.RetiredAt,
+		&q.CreatedAt,
+		&q.Title,
+		&q.Description,
+		&q.InputFormat,
+		&q.OutputFormat,
+		&q.ExampleInput,
+		&q.ExampleOutput,
+		&q.Difficulty,
+		&q.Rating,
+		pq.Array(&q.Tags),
+		&q.TimeLimitMs,
+		&q.MemoryLimitKB,
+		&q.Checker,
+		&q.Tolerance,
+		&q.CheckerSource,
+		&q.CheckerLangID,
+	)
+}
+
 func (s *QuestionStore) create(ctx context.Context, tx *sql.Tx, q *DSAQuestion) error {
+	// The first version of a question starts its own lineage, so its id
+	// is drawn up front to be used for both columns.
 	query := `
-		INSERT INTO dsa_questions 
-		(title, description, input_format, output_format, example_input, example_output)
-		VALUES ($1, $2, $3, $4, $5, $6)
-		RETURNING id
+		INSERT INTO dsa_questions
+		(id, lineage_id, version, title, description, input_format, output_format,
+		example_input, example_output, difficulty, rating, tags, time_limit_ms, memory_limit_kb,
+		checker, checker_tolerance, checker_source, checker_language_id)
+		SELECT next.id, COALESCE(NULLIF($1::int, 0), next.id), $2, $3, $4, $5, $6, $7, $8,
+		$9, $10, $11, $12, $13, $14, $15, $16, $17
+		FROM (SELECT nextval(pg_get_serial_sequence('dsa_questions', 'id')) AS id) next
+		RETURNING id, lineage_id, created_at
 	`
 
+	if !hasSample(q.TestCases) && q.ExampleInput == "" && q.ExampleOutput == "" {
+		return ErrNoSample
+	}
+
+	if q.Version == 0 {
+		q.Version = 1
+	}
+	if q.Checker == "" {
+		q.Checker = "tokens"
+	}
+	if q.Difficulty == "" {
+		q.Difficulty = DifficultyMedium
+	}
+	if q.Rating == 0 {
+		q.Rating = 1500
+	}
+	if q.Tags == nil {
+		q.Tags = []string{}
+	}
+	if q.TimeLimitMs == 0 {
+		q.TimeLimitMs = DefaultTimeLimitMs
+	}
+	if q.MemoryLimitKB == 0 {
+		q.MemoryLimitKB = DefaultMemoryLimitKB
+	}
+
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
 	err := tx.QueryRowContext(
 		ctx,
 		query,
+		q.LineageID,
+		q.Version,
 		q.Title,
 		q.Description,
 		q.InputFormat,
 		q.OutputFormat,
 		q.ExampleInput,
 		q.ExampleOutput,
-	).Scan(&q.ID)
+		q.Difficulty,
+		q.Rating,
+		pq.Array(q.Tags),
+		q.TimeLimitMs,
+		q.MemoryLimitKB,
+		q.Checker,
+		q.Tolerance,
+		q.CheckerSource,
+		q.CheckerLangID,
+	).Scan(&q.ID, &q.LineageID, &q.CreatedAt)
 
 	if err != nil {
 		return err
 	}
 
+	if !hasSample(q.TestCases) {
+		example := TestCase{Input: q.ExampleInput, ExpectedOutput: q.ExampleOutput}
+		q.TestCases = append([]TestCase{example}, q.TestCases...)
+	}
+
+	for i := range q.TestCases {
+		q.TestCases[i].QuestionID = q.ID
+		q.TestCases[i].Position = i
+		if err := s.createTestCase(ctx, tx, &q.TestCases[i]); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
+func (s *QuestionStore) createTestCase(ctx context.Context, tx *sql.Tx, tc *TestCase) error {
+	query := `
+		INSERT INTO question_test_cases (question_id, input, expected_output, is_hidden, position)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id
+	`
+
+	return tx.QueryRowContext(
+		ctx,
+		query,
+		tc.QuestionID,
+		tc.Input,
+		tc.ExpectedOutput,
+		tc.IsHidden,
+		tc.Position,
+	).Scan(&tc.ID)
+}
+
 func (s *QuestionStore) Create(ctx context.Context, q *DSAQuestion) error {
 	return withTx(s.db, ctx, func(tx *sql.Tx) error {
 		return s.create(ctx, tx, q)
 	})
 }
 
-func (s *QuestionStore) GetRandomQuestion(ctx context.Context) (*DSAQuestion, error) {
+// Pick chooses a question for a match. It first looks for one meeting every
+// criterion, then drops the rating window, and finally allows questions the
+// players have already seen, so a match can start as long as any question
+// with the requested difficulty and tag exists.
+func (s *QuestionStore) Pick(ctx context.Context, c QuestionCriteria) (*DSAQuestion, error) {
+	attempts := []QuestionCriteria{c}
+	if c.RatingWindow > 0 {
+		relaxed := c
+		relaxed.RatingWindow = 0
+		attempts = append(attempts, relaxed)
+	}
+	if len(c.ExcludeSeenBy) > 0 {
+		relaxed := c
+		relaxed.RatingWindow = 0
+		relaxed.ExcludeSeenBy = nil
+		attempts = append(attempts, relaxed)
+	}
 
-	query := `
-		SELECT id, title, description, input_format, output_format, example_input, example_output
-		FROM dsa_questions
-		ORDER BY RANDOM()
-		LIMIT 1
-	`
+	for _, attempt := range attempts {
+		candidates, err := s.candidates(ctx, attempt)
+		if err != nil {
+			return nil, err
+		}
+		if len(candidates) > 0 {
+			return &candidates[rand.Intn(len(candidates))], nil
+		}
+	}
+
+	return nil, ErrNotFound
+}
+
+// candidates returns the questions matching c that are closest to its
+// rating. With a rating window, the rating index narrows down the rows to
+// sort. Without one, as in Pick's fallbacks, ABS(rating - $n) cannot use an
+// index, so every live question passing the other filters is scanned; the
+// LIMIT only keeps the sort itself small.
+func (s *QuestionStore) candidates(ctx context.Context, c QuestionCriteria) ([]DSAQuestion, error) {
+	where := []string{"q.retired_at IS NULL"}
+	var args []any
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if c.Difficulty != "" {
+		where = append(where, "q.difficulty = "+arg(c.Difficulty))
+	}
+	if c.Tag != "" {
+		where = append(where, arg(c.Tag)+" = ANY(q.tags)")
+	}
+	if c.RatingWindow > 0 {
+		where = append(where, fmt.Sprintf("q.rating BETWEEN %s AND %s", arg(c.Rating-c.RatingWindow), arg(c.Rating+c.RatingWindow)))
+	}
+	if len(c.ExcludeSeenBy) > 0 {
+		players := arg(pq.Array(c.ExcludeSeenBy))
+		where = append(where, `NOT EXISTS (
+			SELECT 1 FROM matches m
+			JOIN dsa_questions seen ON seen.id = m.question_id
+			WHERE seen.lineage_id = q.lineage_id
+			AND (m.player1_id = ANY(`+players+`) OR m.player2_id = ANY(`+players+`))
+		)`)
+	}
+
+	query := `SELECT ` + questionColumns + ` FROM dsa_questions q WHERE ` + strings.Join(where, " AND ")
+	query += fmt.Sprintf(` ORDER BY ABS(q.rating - %s), q.id LIMIT %s`, arg(c.Rating), arg(pickCandidates))
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	var q DSAQuestion
-	err := s.db.QueryRowContext(ctx, query).Scan(
-		&q.ID,
-		&q.Title,
-		&q.Description,
-		&q.InputFormat,
-		&q.OutputFormat,
-		&q.ExampleInput,
-		&q.ExampleOutput,
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var questions []DSAQuestion
+	for rows.Next() {
+		var q DSAQuestion
+		if err := scanQuestion(rows, &q); err != nil {
+			return nil, err
+		}
+		questions = append(questions, q)
+	}
+
+	return questions, rows.Err()
+}
+
+func (s *QuestionStore) GetByID(ctx context.Context, id int64) (*DSAQuestion, error) {
+	query := `SELECT ` + questionColumns + ` FROM dsa_questions q WHERE q.id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	q := &DSAQuestion{}
+	if err := scanQuestion(s.db.QueryRowContext(ctx, query, id), q); err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return q, nil
+}
+
+// List returns questions newest first. Retired and superseded versions are
+// only included when asked for.
+func (s *QuestionStore) List(ctx context.Context, f QuestionFilter) ([]DSAQuestion, error) {
+	var (
+		where []string
+		args  []any
 	)
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
 
+	if !f.IncludeRetired {
+		where = append(where, "q.retired_at IS NULL")
+	}
+	if f.Difficulty != "" {
+		where = append(where, "q.difficulty = "+arg(f.Difficulty))
+	}
+	if f.Tag != "" {
+		where = append(where, arg(f.Tag)+" = ANY(q.tags)")
+	}
+
+	query := `SELECT ` + questionColumns + ` FROM dsa_questions q`
+	if len(where) > 0 {
+		query += ` WHERE ` + strings.Join(where, " AND ")
+	}
+	query += fmt.Sprintf(` ORDER BY q.id DESC LIMIT %s OFFSET %s`, arg(f.Limit), arg(f.Offset))
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, nil
+		return nil, err
+	}
+	defer rows.Close()
+
+	questions := []DSAQuestion{}
+	for rows.Next() {
+		var q DSAQuestion
+		if err := scanQuestion(rows, &q); err != nil {
+			return nil, err
 		}
+		questions = append(questions, q)
+	}
+
+	return questions, rows.Err()
+}
+
+// Update publishes q as a new version of the question with ID q.ID and
+// retires the old one. Matches and submissions keep pointing at the exact
+// version they were played with. On success q holds the new version.
+func (s *QuestionStore) Update(ctx context.Context, q *DSAQuestion) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		query := `
+			UPDATE dsa_questions SET retired_at = NOW()
+			WHERE id = $1 AND retired_at IS NULL
+			RETURNING lineage_id, version
+		`
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		err := tx.QueryRowContext(ctx, query, q.ID).Scan(&q.LineageID, &q.Version)
+		if err != nil {
+			switch err {
+			case sql.ErrNoRows:
+				return ErrNotFound
+			default:
+				return err
+			}
+		}
+
+		q.Version++
+		return s.create(ctx, tx, q)
+	})
+}
+
+// Retire takes a question out of the pool for new matches.
+func (s *QuestionStore) Retire(ctx context.Context, id int64) error {
+	query := `UPDATE dsa_questions SET retired_at = NOW() WHERE id = $1 AND retired_at IS NULL`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	return expectRows(res, ErrNotFound)
+}
+
+func (s *QuestionStore) GetTestCases(ctx context.Context, questionID int64) ([]TestCase, error) {
+	query := `
+		SELECT id, question_id, input, expected_output, is_hidden, position
+		FROM question_test_cases
+		WHERE question_id = $1
+		ORDER BY position, id
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, questionID)
+	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
+
+	var cases []TestCase
+	for rows.Next() {
+		var tc TestCase
+		if err := rows.Scan(
+			&tc.ID,
+			&tc.QuestionID,
+			&tc.Input,
+			&tc.ExpectedOutput,
+			&tc.IsHidden,
+			&tc.Position,
+		); err != nil {
+			return nil, err
+		}
+		cases = append(cases, tc)
+	}
 
-	return &q, nil
+	return cases, rows.Err()
+}
+
+func hasSample(cases []TestCase) bool {
+	for _, tc := range cases {
+		if !tc.IsHidden {
+			return true
+		}
+	}
+	return false
 }
@@ -0,0 +1,116 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+type RatingStore struct {
+	db *sql.DB
+}
+
+func (s *RatingStore) applyChange(ctx context.Context, tx *sql.Tx, change *RatingChange) error {
+	query := `
+		UPDATE users
+		SET rating = $1, rating_deviation = $2, rating_volatility = $3, points = ROUND($1), updated_at = NOW()
+		WHERE id = $4
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, query, change.RatingAfter, change.DeviationAfter, change.VolatilityAfter, change.UserID)
+	if err != nil {
+		return err
+	}
+
+	query = `
+		INSERT INTO rating_history
+		(match_id, user_id, rating_before, rating_after, deviation_before, deviation_after,
+		volatility_before, volatility_after)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id, created_at
+	`
+
+	return tx.QueryRowContext(
+		ctx,
+		query,
+		change.MatchID,
+		change.UserID,
+		change.RatingBefore,
+		change.RatingAfter,
+		change.DeviationBefore,
+		change.DeviationAfter,
+		change.VolatilityBefore,
+		change.VolatilityAfter,
+	).Scan(&change.ID, &change.CreatedAt)
+}
+
+// RecordMatch stores the match result and every player's new rating in a
+// single transaction so ratings never drift from the match history. A match
+// without an ID is inserted; otherwise the running match is finished.
+func (s *RatingStore) RecordMatch(ctx context.Context, match *Match, changes []RatingChange) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		record := finishMatch
+		if match.ID == 0 {
+			now := time.Now()
+			match.EndedAt = &now
+			record = createMatch
+		}
+
+		if err := record(ctx, tx, match); err != nil {
+			return err
+		}
+
+		for i := range changes {
+			changes[i].MatchID = match.ID
+			if err := s.applyChange(ctx, tx, &changes[i]); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func (s *RatingStore) GetHistoryByUser(ctx context.Context, userID int64) ([]RatingChange, error) {
+	query := `
+		SELECT id, match_id, user_id, rating_before, rating_after, deviation_before, deviation_after,
+		volatility_before, volatility_after, created_at
+		FROM rating_history
+		WHERE user_id = $1
+		ORDER BY created_at DESC, id DESC
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var history []RatingChange
+	for rows.Next() {
+		var c RatingChange
+		if err := rows.Scan(
+			&c.ID,
+			&c.MatchID,
+			&c.UserID,
+			&c.RatingBefore,
+			&c.RatingAfter,
+			&c.DeviationBefore,
+			&c.DeviationAfter,
+			&c.VolatilityBefore,
+			&c.VolatilityAfter,
+			&c.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		history = append(history, c)
+	}
+
+	return history, rows.Err()
+}
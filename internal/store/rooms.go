@@ -0,0 +1,156 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/lib/pq"
+)
+
+type RoomStore struct {
+	db *sql.DB
+}
+
+const roomColumns = `id, code, host_id, guest_id, difficulty, time_limit_seconds, rated, status, created_at, expires_at`
+
+func scanRoom(row interface{ Scan(...any) error }, room *Room) error {
+	return row.Scan(
+		&room.ID,
+		&room.Code,
+		&room.HostID,
+		&room.GuestID,
+		&room.Difficulty,
+		&room.TimeLimitSeconds,
+		&room.Rated,
+		&room.Status,
+		&room.CreatedAt,
+		&room.ExpiresAt,
+	)
+}
+
+func (s *RoomStore) create(ctx context.Context, tx *sql.Tx, room *Room) error {
+	query := `
+		INSERT INTO rooms (code, host_id, guest_id, difficulty, time_limit_seconds, rated, status, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id, created_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	if room.Status == "" {
+		room.Status = RoomStatusOpen
+	}
+
+	err := tx.QueryRowContext(
+		ctx,
+		query,
+		room.Code,
+		room.HostID,
+		room.GuestID,
+		room.Difficulty,
+		room.TimeLimitSeconds,
+		room.Rated,
+		room.Status,
+		room.ExpiresAt,
+	).Scan(&room.ID, &room.CreatedAt)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Constraint == "rooms_code_key" {
+			return ErrConflict
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (s *RoomStore) Create(ctx context.Context, room *Room) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		return s.create(ctx, tx, room)
+	})
+}
+
+func (s *RoomStore) GetByCode(ctx context.Context, code string) (*Room, error) {
+	query := `SELECT ` + roomColumns + ` FROM rooms WHERE code = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	room := &Room{}
+	if err := scanRoom(s.db.QueryRowContext(ctx, query, code), room); err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return room, nil
+}
+
+func (s *RoomStore) ListByUser(ctx context.Context, userID int64) ([]Room, error) {
+	query := `
+		SELECT ` + roomColumns + `
+		FROM rooms
+		WHERE host_id = $1 OR guest_id = $1
+		ORDER BY created_at DESC
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rooms := []Room{}
+	for rows.Next() {
+		var room Room
+		if err := scanRoom(rows, &room); err != nil {
+			return nil, err
+		}
+		rooms = append(rooms, room)
+	}
+
+	return rooms, rows.Err()
+}
+
+// ClaimGuest takes the guest seat of an open room nobody was invited to. It
+// returns ErrConflict when another user got there first.
+func (s *RoomStore) ClaimGuest(ctx context.Context, roomID, userID int64) error {
+	query := `
+		UPDATE rooms SET guest_id = $1
+		WHERE id = $2 AND status = $3 AND host_id <> $1 AND (guest_id IS NULL OR guest_id = $1)
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, userID, roomID, RoomStatusOpen)
+	if err != nil {
+		return err
+	}
+
+	return expectRows(res, ErrConflict)
+}
+
+// UpdateStatus moves a room from one status to another and returns
+// ErrNotFound if the room is no longer in the expected status.
+func (s *RoomStore) UpdateStatus(ctx context.Context, roomID int64, from, to string) error {
+	query := `UPDATE rooms SET status = $1 WHERE id = $2 AND status = $3`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, to, roomID, from)
+	if err != nil {
+		return err
+	}
+
+	return expectRows(res, ErrNotFound)
+}
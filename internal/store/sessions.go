@@ -0,0 +1,151 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+type SessionStore struct {
+	db *sql.DB
+}
+
+const sessionColumns = `id, user_id, user_agent, expires_at, revoked_at, last_used_at, created_at`
+
+func scanSession(row interface{ Scan(...any) error }, session *Session) error {
+	return row.Scan(
+		&session.ID,
+		&session.UserID,
+		&session.UserAgent,
+		&session.ExpiresAt,
+		&session.RevokedAt,
+		&session.LastUsedAt,
+		&session.CreatedAt,
+	)
+}
+
+func (s *SessionStore) create(ctx context.Context, tx *sql.Tx, session *Session, tokenHash []byte) error {
+	query := `
+		INSERT INTO sessions (user_id, refresh_token_hash, user_agent, expires_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, last_used_at, created_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	return tx.QueryRowContext(
+		ctx,
+		query,
+		session.UserID,
+		tokenHash,
+		session.UserAgent,
+		session.ExpiresAt,
+	).Scan(&session.ID, &session.LastUsedAt, &session.CreatedAt)
+}
+
+// Create starts a session whose refresh token hashes to tokenHash. Only
+// the hash is stored.
+func (s *SessionStore) Create(ctx context.Context, session *Session, tokenHash []byte) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		return s.create(ctx, tx, session, tokenHash)
+	})
+}
+
+func (s *SessionStore) GetByID(ctx context.Context, id int64) (*Session, error) {
+	query := `SELECT ` + sessionColumns + ` FROM sessions WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	session := &Session{}
+	if err := scanSession(s.db.QueryRowContext(ctx, query, id), session); err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return session, nil
+}
+
+// Rotate swaps the refresh token hashing to oldHash for newHash and extends
+// the session to expiresAt. Each refresh token works once: presenting the
+// one that was just replaced means it leaked, so the whole session is
+// revoked and ErrNotFound returned.
+func (s *SessionStore) Rotate(ctx context.Context, oldHash, newHash []byte, expiresAt time.Time) (*Session, error) {
+	query := `
+		UPDATE sessions
+		SET previous_token_hash = refresh_token_hash, refresh_token_hash = $1,
+		expires_at = $2, last_used_at = NOW()
+		WHERE refresh_token_hash = $3 AND revoked_at IS NULL AND expires_at > NOW()
+		RETURNING ` + sessionColumns
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	session := &Session{}
+	err := scanSession(s.db.QueryRowContext(ctx, query, newHash, expiresAt, oldHash), session)
+	if err == nil {
+		return session, nil
+	}
+	if err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	revoke := `UPDATE sessions SET revoked_at = NOW() WHERE previous_token_hash = $1 AND revoked_at IS NULL`
+	if _, err := s.db.ExecContext(ctx, revoke, oldHash); err != nil {
+		return nil, err
+	}
+
+	return nil, ErrNotFound
+}
+
+// Revoke ends one of the user's sessions.
+func (s *SessionStore) Revoke(ctx context.Context, id, userID int64) error {
+	query := `UPDATE sessions SET revoked_at = NOW() WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, id, userID)
+	if err != nil {
+		return err
+	}
+
+	return expectRows(res, ErrNotFound)
+}
+
+// RevokeAllForUser ends every active session of the user and returns how
+// many there were.
+func (s *SessionStore) RevokeAllForUser(ctx context.Context, userID int64) (int64, error) {
+	query := `UPDATE sessions SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
+
+// RevokeOthersForUser ends every active session of the user except keepID
+// and returns how many there were.
+func (s *SessionStore) RevokeOthersForUser(ctx context.Context, userID, keepID int64) (int64, error) {
+	query := `UPDATE sessions SET revoked_at = NOW() WHERE user_id = $1 AND id <> $2 AND revoked_at IS NULL`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, userID, keepID)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
@@ -17,11 +17,21 @@ type Storage struct {
 	Users interface {
 		GetByID(context.Context, int64) (*User, error)
 		Create(context.Context, *User) error
-		Update(context.Context, int64, map[string]interface{}) error
+		Update(context.Context, *User) error
 		Delete(context.Context, int64) error
 		GetByEmail(context.Context, string) (*User, error)
-		IncrementPoints(context.Context, int64, int) (int, error)
-		DecrementPoints(context.Context, int64, int) (int, error)
+		GetByUsername(context.Context, string) (*User, error)
+		SetRole(context.Context, int64, string) error
+		VerifyEmail(context.Context, int64) error
+		CreateWithIdentity(context.Context, *User, *UserIdentity) error
+	}
+	UserTokens interface {
+		Create(context.Context, *UserToken, []byte) error
+		Consume(context.Context, []byte, string) (*UserToken, error)
+	}
+	UserIdentities interface {
+		GetBySubject(context.Context, string, string) (*UserIdentity, error)
+		Create(context.Context, *UserIdentity) error
 	}
 	Matches interface {
 		Create(context.Context, *Match) error
@@ -31,15 +41,50 @@ type Storage struct {
 	}
 	Questions interface {
 		Create(context.Context, *DSAQuestion) error
-		GetRandomQuestion(context.Context) (*DSAQuestion, error)
+		Pick(context.Context, QuestionCriteria) (*DSAQuestion, error)
+		GetByID(context.Context, int64) (*DSAQuestion, error)
+		List(context.Context, QuestionFilter) ([]DSAQuestion, error)
+		Update(context.Context, *DSAQuestion) error
+		Retire(context.Context, int64) error
+		GetTestCases(context.Context, int64) ([]TestCase, error)
+	}
+	Ratings interface {
+		RecordMatch(context.Context, *Match, []RatingChange) error
+		GetHistoryByUser(context.Context, int64) ([]RatingChange, error)
+	}
+	Submissions interface {
+		Create(context.Context, *Submission) error
+		GetByID(context.Context, int64) (*Submission, error)
+		ListByUser(context.Context, int64, int, int) ([]Submission, error)
+	}
+	Sessions interface {
+		Create(context.Context, *Session, []byte) error
+		GetByID(context.Context, int64) (*Session, error)
+		Rotate(context.Context, []byte, []byte, time.Time) (*Session, error)
+		Revoke(context.Context, int64, int64) error
+		RevokeAllForUser(context.Context, int64) (int64, error)
+		RevokeOthersForUser(context.Context, int64, int64) (int64, error)
+	}
+	Rooms interface {
+		Create(context.Context, *Room) error
+		GetByCode(context.Context, string) (*Room, error)
+		ListByUser(context.Context, int64) ([]Room, error)
+		ClaimGuest(context.Context, int64, int64) error
+		UpdateStatus(context.Context, int64, string, string) error
 	}
 }
 
 func NewStorage(db *sql.DB) Storage {
 	return Storage{
-		Users:     &UserStore{db},
-		Matches:   &MatchStore{db},
-		Questions: &QuestionStore{db},
+		Users:          &UserStore{db},
+		Matches:        &MatchStore{db},
+		Questions:      &QuestionStore{db},
+		Ratings:        &RatingStore{db},
+		Rooms:          &RoomStore{db},
+		Submissions:    &SubmissionStore{db},
+		Sessions:       &SessionStore{db},
+		UserTokens:     &UserTokenStore{db},
+		UserIdentities: &UserIdentityStore{db},
 	}
 }
 
@@ -56,3 +101,14 @@ func withTx(db *sql.DB, ctx context.Context, fn func(*sql.Tx) error) error {
 
 	return tx.Commit()
 }
+
+func expectRows(res sql.Result, errNone error) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return errNone
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+)
+
+type SubmissionStore struct {
+	db *sql.DB
+}
+
+const submissionColumns = `id, match_id, user_id, question_id, language_id, source_code, verdict,
+	tests_passed, total_tests, failed_test, compile_output, stdout, stderr, runtime_ms, memory_kb, created_at`
+
+func scanSubmission(row interface{ Scan(...any) error }, sub *Submission) error {
+	return row.Scan(
+		&sub.ID,
+		&sub.MatchID,
+		&sub.UserID,
+		&sub.QuestionID,
+		&sub.LanguageID,
+		&sub.SourceCode,
+		&sub.Verdict,
+		&sub.TestsPassed,
+		&sub.TotalTests,
+		&sub.FailedTest,
+		&sub.CompileOutput,
+		&sub.Stdout,
+		&sub.Stderr,
+		&sub.RuntimeMs,
+		&sub.MemoryKB,
+		&sub.CreatedAt,
+	)
+}
+
+func (s *SubmissionStore) Create(ctx context.Context, sub *Submission) error {
+	query := `
+		INSERT INTO submissions
+		(match_id, user_id, question_id, language_id, source_code, verdict,
+		tests_passed, total_tests, failed_test, compile_output, stdout, stderr, runtime_ms, memory_kb)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+		RETURNING id, created_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	return s.db.QueryRowContext(
+		ctx,
+		query,
+		sub.MatchID,
+		sub.UserID,
+		sub.QuestionID,
+		sub.LanguageID,
+		sub.SourceCode,
+		sub.Verdict,
+		sub.TestsPassed,
+		sub.TotalTests,
+		sub.FailedTest,
+		sub.CompileOutput,
+		sub.Stdout,
+		sub.Stderr,
+		sub.RuntimeMs,
+		sub.MemoryKB,
+	).Scan(&sub.ID, &sub.CreatedAt)
+}
+
+func (s *SubmissionStore) GetByID(ctx context.Context, id int64) (*Submission, error) {
+	query := `SELECT ` + submissionColumns + ` FROM submissions WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	sub := &Submission{}
+	if err := scanSubmission(s.db.QueryRowContext(ctx, query, id), sub); err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return sub, nil
+}
+
+func (s *SubmissionStore) ListByUser(ctx context.Context, userID int64, limit, offset int) ([]Submission, error) {
+	query := `
+		SELECT ` + submissionColumns + `
+		FROM submissions
+		WHERE user_id = $1
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2 OFFSET $3
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	subs := []Submission{}
+	for rows.Next() {
+		var sub Submission
+		if err := scanSubmission(rows, &sub); err != nil {
+			return nil, err
+		}
+		subs = append(subs, sub)
+	}
+
+	return subs, rows.Err()
+}
@@ -0,0 +1,78 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+type UserTokenStore struct {
+	db *sql.DB
+}
+
+func (s *UserTokenStore) create(ctx context.Context, tx *sql.Tx, token *UserToken, tokenHash []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	// Only the newest link for a purpose works, so asking for another
+	// email invalidates the ones already sent.
+	query := `DELETE FROM user_tokens WHERE user_id = $1 AND purpose = $2`
+	if _, err := tx.ExecContext(ctx, query, token.UserID, token.Purpose); err != nil {
+		return err
+	}
+
+	query = `
+		INSERT INTO user_tokens (token_hash, user_id, purpose, expires_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING created_at
+	`
+
+	return tx.QueryRowContext(
+		ctx,
+		query,
+		tokenHash,
+		token.UserID,
+		token.Purpose,
+		token.ExpiresAt,
+	).Scan(&token.CreatedAt)
+}
+
+func (s *UserTokenStore) Create(ctx context.Context, token *UserToken, tokenHash []byte) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		return s.create(ctx, tx, token, tokenHash)
+	})
+}
+
+// Consume uses up the token with the given hash and purpose. Unknown and
+// expired tokens give ErrNotFound.
+func (s *UserTokenStore) Consume(ctx context.Context, tokenHash []byte, purpose string) (*UserToken, error) {
+	query := `
+		DELETE FROM user_tokens WHERE token_hash = $1 AND purpose = $2
+		RETURNING user_id, purpose, expires_at, created_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	token := &UserToken{}
+	err := s.db.QueryRowContext(ctx, query, tokenHash, purpose).Scan(
+		&token.UserID,
+		&token.Purpose,
+		&token.ExpiresAt,
+		&token.CreatedAt,
+	)
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	if !time.Now().Before(token.ExpiresAt) {
+		return nil, ErrNotFound
+	}
+
+	return token, nil
+}
@@ -4,8 +4,8 @@ import (
 	"context"
 	"database/sql"
 	"errors"
-	"fmt"
-	"strings"
+
+	"github.com/lib/pq"
 )
 
 var (
@@ -18,7 +18,15 @@ type UserStore struct {
 }
 
 func (s *UserStore) create(ctx context.Context, tx *sql.Tx, user *User) error {
-	query := `INSERT INTO users (email, password, username) VALUES ($1, $2, $3) RETURNING id, created_at, updated_at`
+	query := `
+		INSERT INTO users (email, password, username, role, email_verified_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at, updated_at
+	`
+
+	if user.Role == "" {
+		user.Role = RoleUser
+	}
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
@@ -29,16 +37,11 @@ func (s *UserStore) create(ctx context.Context, tx *sql.Tx, user *User) error {
 		user.Email,
 		user.Password,
 		user.Username,
+		user.Role,
+		user.EmailVerifiedAt,
 	).Scan(&user.ID, &user.CreatedAt, &user.UpdatedAt)
 	if err != nil {
-		switch {
-		case err.Error() == `pq : duplicate key value violates unique constraint "users_email_key"`:
-			return ErrDuplicateEmail
-		case err.Error() == `pq: duplicate key value violates unique constraint "users_username_key`:
-			return ErrDuplicateUsername
-		default:
-			return err
-		}
+		return duplicateUserError(err)
 	}
 
 	return nil
@@ -50,9 +53,23 @@ func (s *UserStore) Create(ctx context.Context, user *User) error {
 	})
 }
 
+// CreateWithIdentity creates a user who signed up through an OAuth provider
+// together with the identity that links them to it.
+func (s *UserStore) CreateWithIdentity(ctx context.Context, user *User, identity *UserIdentity) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		if err := s.create(ctx, tx, user); err != nil {
+			return err
+		}
+
+		identity.UserID = user.ID
+		return createIdentity(ctx, tx, identity)
+	})
+}
+
 func (s *UserStore) GetByID(ctx context.Context, userID int64) (*User, error) {
 	query := `
-		SELECT users.id, email, password, username, points, created_at, updated_at
+		SELECT users.id, email, password, username, points, rating, rating_deviation, rating_volatility,
+		role, email_verified_at, created_at, updated_at
 		FROM users
 		WHERE users.id = $1
 	`
@@ -69,6 +86,11 @@ func (s *UserStore) GetByID(ctx context.Context, userID int64) (*User, error) {
 		&user.Password,
 		&user.Username,
 		&user.Points,
+		&user.Rating,
+		&user.RatingDeviation,
+		&user.RatingVolatility,
+		&user.Role,
+		&user.EmailVerifiedAt,
 		&user.CreatedAt,
 		&user.UpdatedAt,
 	)
@@ -84,35 +106,83 @@ func (s *UserStore) GetByID(ctx context.Context, userID int64) (*User, error) {
 	return user, nil
 }
 
-func (s *UserStore) update(ctx context.Context, tx *sql.Tx, userID int64, updates map[string]interface{}) error {
-	if len(updates) == 0 {
-		return errors.New("no new fields to update")
+// update saves the user's username, email and password. Nothing else on a
+// user can be changed through it. A new email address starts out
+// unverified.
+func (s *UserStore) update(ctx context.Context, tx *sql.Tx, user *User) error {
+	query := `
+		UPDATE users SET username = $1, email = $2, password = $3, updated_at = NOW(),
+		email_verified_at = CASE WHEN email = $2 THEN email_verified_at END
+		WHERE id = $4
+		RETURNING email_verified_at, updated_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	err := tx.QueryRowContext(ctx, query, user.Username, user.Email, user.Password, user.ID).Scan(&user.EmailVerifiedAt, &user.UpdatedAt)
+	if err != nil {
+		switch {
+		case err == sql.ErrNoRows:
+			return ErrNotFound
+		default:
+			return duplicateUserError(err)
+		}
 	}
 
-	query := `UPDATE users SET`
-	args := []interface{}{}
-	counter := 1
+	return nil
+}
+
+func (s *UserStore) Update(ctx context.Context, user *User) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		return s.update(ctx, tx, user)
+	})
+}
+
+// duplicateUserError turns a unique violation on the email or username into
+// the matching store error.
+func duplicateUserError(err error) error {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) || pqErr.Code != "23505" {
+		return err
+	}
 
-	for key, val := range updates {
-		query += fmt.Sprintf(" %s = $%d,", key, counter)
-		args = append(args, val)
-		counter++
+	switch pqErr.Constraint {
+	case "users_email_key":
+		return ErrDuplicateEmail
+	case "users_username_key":
+		return ErrDuplicateUsername
+	default:
+		return err
 	}
+}
 
-	query = strings.TrimSuffix(query, ",") + fmt.Sprintf(" WHERE id = $%d", counter)
-	args = append(args, userID)
+func (s *UserStore) VerifyEmail(ctx context.Context, userID int64) error {
+	query := `UPDATE users SET email_verified_at = COALESCE(email_verified_at, NOW()) WHERE id = $1`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	_, err := tx.ExecContext(ctx, query, args...)
-	return err
+	res, err := s.db.ExecContext(ctx, query, userID)
+	if err != nil {
+		return err
+	}
+
+	return expectRows(res, ErrNotFound)
 }
 
-func (s *UserStore) Update(ctx context.Context, userID int64, updates map[string]interface{}) error {
-	return withTx(s.db, ctx, func(tx *sql.Tx) error {
-		return s.update(ctx, tx, userID, updates)
-	})
+func (s *UserStore) SetRole(ctx context.Context, userID int64, role string) error {
+	query := `UPDATE users SET role = $1, updated_at = NOW() WHERE id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, role, userID)
+	if err != nil {
+		return err
+	}
+
+	return expectRows(res, ErrNotFound)
 }
 
 func (s *UserStore) delete(ctx context.Context, tx *sql.Tx, userID int64) error {
@@ -136,7 +206,12 @@ func (s *UserStore) Delete(ctx context.Context, userID int64) error {
 }
 
 func (s *UserStore) GetByEmail(ctx context.Context, email string) (*User, error) {
-	query := `SELECT id, email, username, password, points, created_at, updated_at FROM users WHERE email = $1`
+	query := `
+		SELECT id, email, username, password, points, rating, rating_deviation, rating_volatility,
+		role, email_verified_at, created_at, updated_at
+		FROM users
+		WHERE email = $1
+	`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
@@ -148,6 +223,11 @@ func (s *UserStore) GetByEmail(ctx context.Context, email string) (*User, error)
 		&user.Username,
 		&user.Password,
 		&user.Points,
+		&user.Rating,
+		&user.RatingDeviation,
+		&user.RatingVolatility,
+		&user.Role,
+		&user.EmailVerifiedAt,
 		&user.CreatedAt,
 		&user.UpdatedAt,
 	)
@@ -163,16 +243,40 @@ func (s *UserStore) GetByEmail(ctx context.Context, email string) (*User, error)
 	return user, nil
 }
 
-func (m *UserStore) IncrementPoints(ctx context.Context, userID int64, amount int) (int, error) {
-	query := `UPDATE users SET points = points + $1 WHERE id = $2 RETURNING points`
-	var newPoints int
-	err := m.db.QueryRowContext(ctx, query, amount, userID).Scan(&newPoints)
-	return newPoints, err
-}
+func (s *UserStore) GetByUsername(ctx context.Context, username string) (*User, error) {
+	query := `
+		SELECT id, email, username, password, points, rating, rating_deviation, rating_volatility,
+		role, email_verified_at, created_at, updated_at
+		FROM users
+		WHERE username = $1
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	user := &User{}
+	err := s.db.QueryRowContext(ctx, query, username).Scan(
+		&user.ID,
+		&user.Email,
+		&user.Username,
+		&user.Password,
+		&user.Points,
+		&user.Rating,
+		&user.RatingDeviation,
+		&user.RatingVolatility,
+		&user.Role,
+		&user.EmailVerifiedAt,
+		&user.CreatedAt,
+		&user.UpdatedAt,
+	)
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
 
-func (m *UserStore) DecrementPoints(ctx context.Context, userID int64, amount int) (int, error) {
-	query := `UPDATE users SET points = GREATEST(points - $1, 0) WHERE id = $2 RETURNING points`
-	var newPoints int
-	err := m.db.QueryRowContext(ctx, query, amount, userID).Scan(&newPoints)
-	return newPoints, err
+	return user, nil
 }
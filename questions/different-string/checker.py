@@ -0,0 +1,38 @@
+import sys
+
+data = sys.stdin.buffer.read()
+nl = data.index(b"\n")
+a, b, c = map(int, data[:nl].split())
+rest = data[nl + 1:]
+tests = rest[:a].decode().split()
+output = rest[a + b:a + b + c].decode().split()
+
+
+def reject():
+    print("WA")
+    sys.exit(0)
+
+
+pos = 0
+for s in tests[1:1 + int(tests[0])]:
+    if pos >= len(output):
+        reject()
+    word = output[pos].upper()
+    pos += 1
+    possible = len(set(s)) > 1
+    if word == "NO":
+        if possible:
+            reject()
+    elif word == "YES":
+        if not possible or pos >= len(output):
+            reject()
+        r = output[pos]
+        pos += 1
+        if r == s or sorted(r) != sorted(s):
+            reject()
+    else:
+        reject()
+
+if pos != len(output):
+    reject()
+print("OK")
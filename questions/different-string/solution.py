@@ -0,0 +1,21 @@
+import sys
+
+
+def main():
+    data = sys.stdin.read().split()
+    out = []
+    for s in data[1:1 + int(data[0])]:
+        if len(set(s)) == 1:
+            out.append("NO")
+            continue
+        r = s[1:] + s[0]
+        if r == s:
+            r = "".join(sorted(s))
+            if r == s:
+                r = r[::-1]
+        out.append("YES")
+        out.append(r)
+    print("\n".join(out))
+
+
+main()
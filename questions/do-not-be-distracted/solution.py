@@ -0,0 +1,21 @@
+import sys
+
+
+def main():
+    data = sys.stdin.read().split()
+    t = int(data[0])
+    out = []
+    for i in range(t):
+        s = data[2 + 2 * i]
+        seen = set()
+        ok = True
+        for j, c in enumerate(s):
+            if c in seen and s[j - 1] != c:
+                ok = False
+                break
+            seen.add(c)
+        out.append("YES" if ok else "NO")
+    print("\n".join(out))
+
+
+main()
@@ -0,0 +1,20 @@
+import sys
+
+
+def main():
+    data = sys.stdin.read().split()
+    t = int(data[0])
+    pos = 1
+    out = []
+    for _ in range(t):
+        n = int(data[pos])
+        a = list(map(int, data[pos + 1:pos + 1 + n]))
+        pos += 1 + n
+        ones = a.count(1)
+        twos = a.count(2)
+        ok = ones % 2 == 0 and (ones > 0 or twos % 2 == 0)
+        out.append("YES" if ok else "NO")
+    print("\n".join(out))
+
+
+main()
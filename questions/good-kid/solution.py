@@ -0,0 +1,21 @@
+import sys
+
+
+def main():
+    data = sys.stdin.read().split()
+    t = int(data[0])
+    pos = 1
+    out = []
+    for _ in range(t):
+        n = int(data[pos])
+        a = sorted(map(int, data[pos + 1:pos + 1 + n]))
+        pos += 1 + n
+        a[0] += 1
+        product = 1
+        for x in a:
+            product *= x
+        out.append(str(product))
+    print("\n".join(out))
+
+
+main()
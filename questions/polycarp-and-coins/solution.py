@@ -0,0 +1,18 @@
+import sys
+
+
+def main():
+    data = sys.stdin.read().split()
+    out = []
+    for n in map(int, data[1:1 + int(data[0])]):
+        c2 = n // 3
+        c1 = c2
+        if n % 3 == 1:
+            c1 += 1
+        elif n % 3 == 2:
+            c2 += 1
+        out.append(f"{c1} {c2}")
+    print("\n".join(out))
+
+
+main()